@@ -0,0 +1,11 @@
+//go:build !unix
+
+package filieutil
+
+import "errors"
+
+// FreeSpace get the free bytes available to the user on the file system of the path
+// not supported on this platform
+func FreeSpace(path string) (uint64, error) {
+	return 0, errors.New("free space is not supported on this platform")
+}
@@ -0,0 +1,14 @@
+//go:build unix
+
+package filieutil
+
+import "syscall"
+
+// FreeSpace get the free bytes available to the user on the file system of the path
+func FreeSpace(path string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+	return uint64(stat.Bavail) * uint64(stat.Bsize), nil
+}
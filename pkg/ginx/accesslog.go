@@ -0,0 +1,101 @@
+package ginx
+
+import (
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// AccessLogConfig access log middleware config
+type AccessLogConfig struct {
+	SkipPaths     []string      // raw paths or route templates not logged, demo: /healthz
+	SlowThreshold time.Duration // slower requests are logged at warn level, 0 disable
+	Sample2xx     uint64        // log one of every n fast 2xx requests, 0 and 1 log all
+	SubjectClaim  string        // claim used as the subject, default SubjectClaim
+}
+
+// AccessLog write one structured line per request through pkg/log
+// 5xx are logged at error level, slow requests at warn level and the others at info level
+func AccessLog(cfg AccessLogConfig) gin.HandlerFunc {
+	skip := make(map[string]bool, len(cfg.SkipPaths))
+	for _, path := range cfg.SkipPaths {
+		skip[path] = true
+	}
+	if cfg.SubjectClaim == "" {
+		cfg.SubjectClaim = SubjectClaim
+	}
+	var counter uint64
+
+	return func(c *gin.Context) {
+		if skip[c.Request.URL.Path] || skip[c.FullPath()] {
+			c.Next()
+			return
+		}
+		start := time.Now()
+		body := &countReader{ReadCloser: c.Request.Body}
+		if c.Request.Body != nil {
+			c.Request.Body = body
+		}
+
+		c.Next()
+
+		latency := time.Since(start)
+		status := c.Writer.Status()
+		level := zapcore.InfoLevel
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = zapcore.ErrorLevel
+		case cfg.SlowThreshold > 0 && latency >= cfg.SlowThreshold:
+			level = zapcore.WarnLevel
+		case status < http.StatusMultipleChoices && cfg.Sample2xx > 1:
+			if atomic.AddUint64(&counter, 1)%cfg.Sample2xx != 1 {
+				return
+			}
+		}
+
+		l := logger()
+		ce := l.Check(level, "access")
+		if ce == nil {
+			return
+		}
+		bytesIn := c.Request.ContentLength
+		if bytesIn < 0 {
+			bytesIn = body.n
+		}
+		bytesOut := c.Writer.Size()
+		if bytesOut < 0 {
+			bytesOut = 0
+		}
+		ce.Write(
+			zap.String("method", c.Request.Method),
+			zap.String("route", c.FullPath()),
+			zap.Int("status", status),
+			zap.Duration("latency", latency),
+			zap.Int64("bytesIn", bytesIn),
+			zap.Int("bytesOut", bytesOut),
+			zap.String("clientIp", c.ClientIP()),
+			zap.String("userAgent", c.Request.UserAgent()),
+			zap.String("requestId", RequestID(c)),
+			zap.String("traceId", traceID(c)),
+			zap.String("subject", Claims(c)[cfg.SubjectClaim]),
+		)
+	}
+}
+
+// countReader count the bytes read from a chunked request body
+type countReader struct {
+	io.ReadCloser
+	n int64
+}
+
+// Read count the bytes
+func (r *countReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,126 @@
+package ginx
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+)
+
+// App ginx application
+// every app owns its gin engine and routers, so several apps can live in one process
+type App struct {
+	engine      *gin.Engine           // gin engine
+	routers     []Router              // routers
+	started     []Router              // routers started by Start, in start order
+	routeOwners []routeEntry          // routes registered by the routers
+	statics     []*staticMount        // static assets served on unmatched paths
+	noRoute     []gin.HandlerFunc     // handlers of the paths no route nor static asset matches
+	operations  map[string]*Operation // route documents by method and path
+	limiters    []*ConcurrencyLimiter // concurrency limiters exposed by the metrics
+	mu          sync.Mutex
+
+	shutdownHooks []ShutdownHook // called on graceful shutdown
+	drainHooks    []func()       // called when the server starts draining
+	shuttingDown  atomic.Bool    // set once the graceful shutdown starts
+}
+
+// Option app option
+type Option func(*App)
+
+// WithMiddleware use middleware when the app is created
+func WithMiddleware(middleware ...gin.HandlerFunc) Option {
+	return func(a *App) {
+		a.engine.Use(middleware...)
+	}
+}
+
+// WithRouters add routers when the app is created
+func WithRouters(routers ...Router) Option {
+	return func(a *App) {
+		a.routers = append(a.routers, routers...)
+	}
+}
+
+// New create a ginx app
+// if engine is nil then use gin.New()
+func New(engine *gin.Engine, opts ...Option) *App {
+	if engine == nil {
+		engine = gin.New()
+	}
+	a := &App{
+		engine:  engine,
+		routers: make([]Router, 0),
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// Use use middleware
+func (a *App) Use(middleware ...gin.HandlerFunc) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.engine.Use(middleware...)
+}
+
+// AddRouters add router slice
+func (a *App) AddRouters(routers ...Router) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.routers = append(a.routers, routers...)
+}
+
+// AddGroupRouters add group router slice
+// the group routers are executed with the other routers in insertion order
+func (a *App) AddGroupRouters(routers ...GroupRouter) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, router := range routers {
+		a.routers = append(a.routers, groupRouter{router})
+	}
+}
+
+// RouterExecute execute router, panic like gin on a route conflict, see RouterExecuteE
+func (a *App) RouterExecute() {
+	if err := a.RouterExecuteE(); err != nil {
+		panic(err)
+	}
+}
+
+// RouterExecuteE execute router and return the error instead of panicking
+// routers run after the routers they depend on, see RouterDepender
+// the routes each router registers are recorded with their owner, a route conflict is
+// reported with the owners of both routes instead of panicking in gin;
+// the engine is left half registered then, so the app should not be served
+func (a *App) RouterExecuteE() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	sorted, err := sortRouters(a.routers)
+	if err != nil {
+		return err
+	}
+	known := a.knownEntries()
+	for _, router := range sorted {
+		added, err := executeRouter(a.engine, router, known)
+		a.routeOwners = append(a.routeOwners, added...)
+		known = append(known, added...)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Engine get gin engine
+func (a *App) Engine() *gin.Engine {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.engine
+}
+
+// ShuttingDown report whether the graceful shutdown started
+func (a *App) ShuttingDown() bool {
+	return a.shuttingDown.Load()
+}
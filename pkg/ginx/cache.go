@@ -0,0 +1,441 @@
+package ginx
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CacheConfig response cache config
+type CacheConfig struct {
+	MaxEntries  int           // default 1000
+	MaxBytes    int64         // total body bytes, default 64MB
+	TTL         time.Duration // default 1m
+	VaryHeaders []string      // request headers in the cache key, demo: Accept-Language
+	WeakETag    bool          // generate W/ etags
+}
+
+// CacheRule cache rule of a route or group
+type CacheRule struct {
+	TTL  time.Duration // default the config TTL
+	Tags []string      // invalidation tags, demo: user
+}
+
+// ResponseCache in-memory LRU cache of GET responses
+// the key is the method, the path, the sorted query, the vary headers and the request subject;
+// only 200 responses without Set-Cookie, no-store or private are stored, neither are the ones
+// the handler Vary on a request header out of VaryHeaders.
+// the responses to requests with Authorization or Cookie are stored and served from the cache
+// only when they are Cache-Control public.
+// only the representation headers are stored, the per-request ones such as
+// Access-Control-Allow-Origin or X-Request-ID are set by the middlewares of each request
+type ResponseCache struct {
+	cfg   CacheConfig
+	mu    sync.Mutex
+	lru   *list.List // front is the most recent
+	items map[string]*list.Element
+	bytes int64
+	calls map[string]*cacheCall // singleflight calls in progress
+}
+
+// cachedHeaders response headers stored with the body
+var cachedHeaders = []string{
+	"Cache-Control",
+	"Content-Disposition",
+	"Content-Encoding",
+	"Content-Language",
+	"Content-Location",
+	"Content-Type",
+	"ETag",
+	"Expires",
+	"Last-Modified",
+}
+
+// cacheEntry cached response
+type cacheEntry struct {
+	key      string
+	route    string
+	tags     []string
+	status   int
+	header   http.Header
+	body     []byte
+	etag     string
+	public   bool // Cache-Control public, shared with the requests with credentials
+	modified time.Time
+	stored   time.Time
+	expire   time.Time
+}
+
+// cacheCall handler run shared by concurrent identical requests
+type cacheCall struct {
+	done  chan struct{}
+	entry *cacheEntry // nil if the response is not cacheable
+}
+
+// NewResponseCache create a response cache
+func NewResponseCache(cfg CacheConfig) *ResponseCache {
+	if cfg.MaxEntries <= 0 {
+		cfg.MaxEntries = 1000
+	}
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = 64 << 20
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = time.Minute
+	}
+	return &ResponseCache{
+		cfg:   cfg,
+		lru:   list.New(),
+		items: make(map[string]*list.Element),
+		calls: make(map[string]*cacheCall),
+	}
+}
+
+// Middleware cache the GET and HEAD responses of the routes
+// If-None-Match and If-Modified-Since are answered with 304,
+// concurrent identical requests wait for one handler run
+func (rc *ResponseCache) Middleware(rule CacheRule) gin.HandlerFunc {
+	if rule.TTL <= 0 {
+		rule.TTL = rc.cfg.TTL
+	}
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+			c.Next()
+			return
+		}
+		key := rc.key(c)
+		bypass := strings.Contains(c.GetHeader("Cache-Control"), "no-cache")
+		credentialed := c.GetHeader("Authorization") != "" || c.GetHeader("Cookie") != ""
+		if !bypass {
+			if e := rc.get(key); e != nil && (e.public || !credentialed) {
+				rc.serve(c, e, "HIT")
+				return
+			}
+		}
+
+		call, leader := rc.join(key)
+		if !leader {
+			select {
+			case <-call.done:
+			case <-c.Request.Context().Done():
+				Fail(c, c.Request.Context().Err())
+				return
+			}
+			if call.entry != nil && (call.entry.public || !credentialed) {
+				rc.serve(c, call.entry, "HIT")
+				return
+			}
+			// not cacheable, run the handler
+			c.Next()
+			return
+		}
+
+		var entry *cacheEntry
+		// the Vary values of the outer middlewares are set again on every request
+		outerVary := len(c.Writer.Header().Values("Vary"))
+		w := newBufferWriter(c.Writer)
+		defer func() {
+			// on panic the recovery middleware writes its error to the client
+			if c.Writer == gin.ResponseWriter(w) {
+				c.Writer = w.ResponseWriter
+			}
+			rc.leave(key, call, entry)
+		}()
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+		if w.passthrough {
+			return
+		}
+		entry = rc.entry(key, c.FullPath(), rule, w, outerVary)
+		if entry != nil && credentialed && !entry.public {
+			entry = nil
+		}
+		if entry == nil {
+			w.flush()
+			return
+		}
+		rc.put(entry)
+		rc.serve(c, entry, "MISS")
+	}
+}
+
+// key cache key of the request
+func (rc *ResponseCache) key(c *gin.Context) string {
+	var sb strings.Builder
+	sb.WriteString(c.Request.Method)
+	sb.WriteByte(' ')
+	sb.WriteString(c.Request.URL.Path)
+	query := c.Request.URL.Query()
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for i, k := range keys {
+		if i == 0 {
+			sb.WriteByte('?')
+		} else {
+			sb.WriteByte('&')
+		}
+		vs := append([]string(nil), query[k]...)
+		sort.Strings(vs)
+		sb.WriteString(url.QueryEscape(k) + "=" + url.QueryEscape(strings.Join(vs, ",")))
+	}
+	for _, h := range rc.cfg.VaryHeaders {
+		sb.WriteString("|" + h + "=" + c.GetHeader(h))
+	}
+	sb.WriteString("|" + Claims(c)[SubjectClaim])
+	return sb.String()
+}
+
+// entry build the cache entry of the captured response, nil if not cacheable
+func (rc *ResponseCache) entry(key, route string, rule CacheRule, w *bufferWriter, outerVary int) *cacheEntry {
+	h := w.Header()
+	vary := h.Values("Vary")
+	if outerVary <= len(vary) {
+		vary = vary[outerVary:]
+	}
+	cc := strings.ToLower(h.Get("Cache-Control"))
+	if w.status != http.StatusOK || h.Get("Set-Cookie") != "" ||
+		strings.Contains(cc, "no-store") || strings.Contains(cc, "private") ||
+		int64(w.buf.Len()) > rc.cfg.MaxBytes || !rc.varies(vary) {
+		return nil
+	}
+	header := make(http.Header)
+	for _, k := range cachedHeaders {
+		if v := h.Values(k); len(v) > 0 {
+			header[k] = append([]string(nil), v...)
+		}
+	}
+	if len(vary) > 0 {
+		header["Vary"] = append([]string(nil), vary...)
+	}
+	now := time.Now()
+	e := &cacheEntry{
+		key:      key,
+		route:    route,
+		tags:     rule.Tags,
+		status:   w.status,
+		header:   header,
+		body:     append([]byte(nil), w.buf.Bytes()...),
+		etag:     h.Get("ETag"),
+		public:   hasDirective(cc, "public"),
+		modified: now.Truncate(time.Second),
+		stored:   now,
+		expire:   now.Add(rule.TTL),
+	}
+	if e.etag == "" {
+		sum := sha256.Sum256(e.body)
+		e.etag = `"` + hex.EncodeToString(sum[:16]) + `"`
+		if rc.cfg.WeakETag {
+			e.etag = "W/" + e.etag
+		}
+		e.header.Set("ETag", e.etag)
+	}
+	if lm, err := http.ParseTime(h.Get("Last-Modified")); err == nil {
+		e.modified = lm
+	} else {
+		e.header.Set("Last-Modified", e.modified.UTC().Format(http.TimeFormat))
+	}
+	return e
+}
+
+// varies report whether the key covers the request headers of the Vary values
+func (rc *ResponseCache) varies(vary []string) bool {
+	for _, v := range vary {
+		for _, name := range strings.Split(v, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			covered := false
+			for _, vh := range rc.cfg.VaryHeaders {
+				if strings.EqualFold(name, vh) {
+					covered = true
+					break
+				}
+			}
+			if !covered {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// serve write the entry, or 304 if the client copy is fresh
+// the headers set by the middlewares of the request are kept, Vary is merged
+func (rc *ResponseCache) serve(c *gin.Context, e *cacheEntry, state string) {
+	h := c.Writer.Header()
+	for k, v := range e.header {
+		switch _, ok := h[k]; {
+		case k == "Vary":
+			for _, vv := range v {
+				if !headerHasValue(h, k, vv) {
+					h.Add(k, vv)
+				}
+			}
+		case !ok:
+			h[k] = append([]string(nil), v...)
+		}
+	}
+	h.Set("X-Cache", state)
+	h.Set("Age", strconv.Itoa(int(time.Since(e.stored).Seconds())))
+	if notModified(c.Request, e) {
+		h.Del("Content-Length")
+		h.Del("Content-Type")
+		c.AbortWithStatus(http.StatusNotModified)
+		return
+	}
+	c.Status(e.status)
+	if c.Request.Method != http.MethodHead {
+		_, _ = c.Writer.Write(e.body)
+	}
+	c.Abort()
+}
+
+// headerHasValue report whether the header has the exact value
+func headerHasValue(h http.Header, key, value string) bool {
+	for _, v := range h.Values(key) {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// hasDirective report whether the lower case Cache-Control value has the directive
+func hasDirective(cc, name string) bool {
+	for _, d := range strings.Split(cc, ",") {
+		if d, _, _ = strings.Cut(d, "="); strings.TrimSpace(d) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified evaluate If-None-Match, or If-Modified-Since when there is no If-None-Match
+func notModified(r *http.Request, e *cacheEntry) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, tag := range strings.Split(inm, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(e.etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+	ims, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	return err == nil && !e.modified.After(ims)
+}
+
+// get a fresh entry
+func (rc *ResponseCache) get(key string) *cacheEntry {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	el, ok := rc.items[key]
+	if !ok {
+		return nil
+	}
+	e := el.Value.(*cacheEntry)
+	if time.Now().After(e.expire) {
+		rc.remove(el)
+		return nil
+	}
+	rc.lru.MoveToFront(el)
+	return e
+}
+
+// put store the entry and evict the least recently used ones over the limits
+func (rc *ResponseCache) put(e *cacheEntry) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if el, ok := rc.items[e.key]; ok {
+		rc.remove(el)
+	}
+	rc.items[e.key] = rc.lru.PushFront(e)
+	rc.bytes += int64(len(e.body))
+	for rc.lru.Len() > rc.cfg.MaxEntries || rc.bytes > rc.cfg.MaxBytes {
+		rc.remove(rc.lru.Back())
+	}
+}
+
+// remove drop the element, rc.mu is held
+func (rc *ResponseCache) remove(el *list.Element) {
+	e := rc.lru.Remove(el).(*cacheEntry)
+	delete(rc.items, e.key)
+	rc.bytes -= int64(len(e.body))
+}
+
+// join the call of the key, leader is true if the caller must run the handler
+func (rc *ResponseCache) join(key string) (*cacheCall, bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if call, ok := rc.calls[key]; ok {
+		return call, false
+	}
+	call := &cacheCall{done: make(chan struct{})}
+	rc.calls[key] = call
+	return call, true
+}
+
+// leave publish the result to the waiting requests
+func (rc *ResponseCache) leave(key string, call *cacheCall, e *cacheEntry) {
+	rc.mu.Lock()
+	delete(rc.calls, key)
+	rc.mu.Unlock()
+	call.entry = e
+	close(call.done)
+}
+
+// InvalidateRoute drop the entries of a route template, demo: /users/:id
+func (rc *ResponseCache) InvalidateRoute(route string) int {
+	return rc.invalidate(func(e *cacheEntry) bool {
+		return e.route == route
+	})
+}
+
+// InvalidateTags drop the entries tagged with any of the tags
+func (rc *ResponseCache) InvalidateTags(tags ...string) int {
+	return rc.invalidate(func(e *cacheEntry) bool {
+		for _, t := range e.tags {
+			for _, tag := range tags {
+				if t == tag {
+					return true
+				}
+			}
+		}
+		return false
+	})
+}
+
+// Purge drop all the entries
+func (rc *ResponseCache) Purge() {
+	rc.invalidate(func(*cacheEntry) bool { return true })
+}
+
+// invalidate drop the matching entries and return their count
+func (rc *ResponseCache) invalidate(match func(*cacheEntry) bool) int {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	n := 0
+	for el := rc.lru.Front(); el != nil; {
+		next := el.Next()
+		if match(el.Value.(*cacheEntry)) {
+			rc.remove(el)
+			n++
+		}
+		el = next
+	}
+	return n
+}
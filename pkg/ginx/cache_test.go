@@ -0,0 +1,143 @@
+package ginx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestResponseCacheHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name   string
+		vary   []string // cache VaryHeaders
+		inner  string   // Vary set by the handler
+		second string   // X-Cache of the second request, empty when not cached
+	}{
+		{"outer vary only", nil, "", "HIT"},
+		{"handler vary out of the key", nil, "Accept-Language", ""},
+		{"handler vary in the key", []string{"Accept-Language"}, "Accept-Language", "HIT"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rc := NewResponseCache(CacheConfig{VaryHeaders: tt.vary})
+			e := gin.New()
+			e.Use(func(c *gin.Context) {
+				// per-request headers of an outer middleware
+				if id := c.GetHeader("X-Request-ID"); id != "" {
+					c.Header("X-Request-ID", id)
+				}
+				c.Writer.Header().Add("Vary", "Origin")
+			})
+			e.GET("/items", rc.Middleware(CacheRule{}), func(c *gin.Context) {
+				if tt.inner != "" {
+					c.Writer.Header().Add("Vary", tt.inner)
+				}
+				c.Header("Cache-Control", "public, max-age=60")
+				c.String(http.StatusOK, "items")
+			})
+
+			states := make([]string, 0, 2)
+			for i, id := range []string{"first", ""} {
+				req := httptest.NewRequest(http.MethodGet, "/items", nil)
+				if id != "" {
+					req.Header.Set("X-Request-ID", id)
+				}
+				w := httptest.NewRecorder()
+				e.ServeHTTP(w, req)
+				if w.Code != http.StatusOK || w.Body.String() != "items" {
+					t.Fatalf("request %d: %d %q", i, w.Code, w.Body.String())
+				}
+				if got := w.Header().Get("X-Request-ID"); got != id {
+					t.Fatalf("request %d: X-Request-ID %q, want %q", i, got, id)
+				}
+				if got := w.Header().Get("Cache-Control"); got != "public, max-age=60" {
+					t.Fatalf("request %d: Cache-Control %q", i, got)
+				}
+				want := []string{"Origin"}
+				if tt.inner != "" {
+					want = append(want, tt.inner)
+				}
+				if got := w.Header().Values("Vary"); len(got) != len(want) {
+					t.Fatalf("request %d: Vary %q, want %q", i, got, want)
+				}
+				states = append(states, w.Header().Get("X-Cache"))
+			}
+			if states[1] != tt.second {
+				t.Fatalf("second request %s, want %s", states[1], tt.second)
+			}
+		})
+	}
+}
+
+func TestResponseCacheCredentials(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name   string
+		header string // request header with credentials
+		cc     string // Cache-Control of the handler
+		second string // X-Cache of the second request, empty when not cached
+	}{
+		{"anonymous", "", "max-age=60", "HIT"},
+		{"authorization", "Authorization", "max-age=60", ""},
+		{"cookie", "Cookie", "max-age=60", ""},
+		{"authorization public", "Authorization", "public, max-age=60", "HIT"},
+		{"cookie public", "Cookie", "public, max-age=60", "HIT"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rc := NewResponseCache(CacheConfig{})
+			e := gin.New()
+			e.GET("/me", rc.Middleware(CacheRule{}), func(c *gin.Context) {
+				c.Header("Cache-Control", tt.cc)
+				c.String(http.StatusOK, "me")
+			})
+			var state string
+			for i := 0; i < 2; i++ {
+				req := httptest.NewRequest(http.MethodGet, "/me", nil)
+				if tt.header != "" {
+					req.Header.Set(tt.header, "secret")
+				}
+				w := httptest.NewRecorder()
+				e.ServeHTTP(w, req)
+				if w.Code != http.StatusOK || w.Body.String() != "me" {
+					t.Fatalf("request %d: %d %q", i, w.Code, w.Body.String())
+				}
+				state = w.Header().Get("X-Cache")
+			}
+			if state != tt.second {
+				t.Fatalf("second request %q, want %q", state, tt.second)
+			}
+		})
+	}
+}
+
+func TestResponseCachePrivateEntry(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	rc := NewResponseCache(CacheConfig{})
+	e := gin.New()
+	e.GET("/page", rc.Middleware(CacheRule{}), func(c *gin.Context) {
+		c.Header("Cache-Control", "max-age=60")
+		if _, err := c.Cookie("session"); err == nil {
+			c.String(http.StatusOK, "member")
+			return
+		}
+		c.String(http.StatusOK, "guest")
+	})
+	// the anonymous response is cached but not served to a request with a cookie
+	for _, cookie := range []string{"", "", "session=1"} {
+		req := httptest.NewRequest(http.MethodGet, "/page", nil)
+		want := "guest"
+		if cookie != "" {
+			req.Header.Set("Cookie", cookie)
+			want = "member"
+		}
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		if w.Body.String() != want {
+			t.Fatalf("cookie %q: body %q, want %q", cookie, w.Body.String(), want)
+		}
+	}
+}
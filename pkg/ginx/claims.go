@@ -0,0 +1,64 @@
+package ginx
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/miajio/gin-screw/pkg/jwt"
+)
+
+// SubjectClaim default claim name of the request subject
+const SubjectClaim = "sub"
+
+const claimsKey = "ginx.claims"
+
+// SetClaims store the pkg/jwt params of the authenticated request
+func SetClaims(c *gin.Context, claims map[string]string) {
+	c.Set(claimsKey, claims)
+}
+
+// Claims get the pkg/jwt params of the authenticated request, nil if not authenticated
+func Claims(c *gin.Context) map[string]string {
+	if v, ok := c.Get(claimsKey); ok {
+		claims, _ := v.(map[string]string)
+		return claims
+	}
+	return nil
+}
+
+// JWTAuth authenticate requests with a pkg/jwt token
+// the token is read from the Authorization Bearer header, or from the token query param
+// for the clients that can not set headers, demo: EventSource
+func JWTAuth(secret string) gin.HandlerFunc {
+	if secret == "" {
+		panic("jwt auth needs a secret")
+	}
+	return func(c *gin.Context) {
+		if err := authenticate(c, secret); err != nil {
+			Fail(c, err)
+			return
+		}
+		c.Next()
+	}
+}
+
+// authenticate verify the token of the request and store its claims
+func authenticate(c *gin.Context, secret string) error {
+	token := c.Query("token")
+	if v, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok {
+		token = strings.TrimSpace(v)
+	}
+	if token == "" {
+		return ErrUnauthorized.WithMessage("missing token")
+	}
+	claims, err := jwt.DecryptionToken(token, secret)
+	if errors.Is(err, jwt.ErrExpired) {
+		return ErrUnauthorized.WithMessage("token expired").Wrap(err)
+	}
+	if err != nil {
+		return ErrUnauthorized.WithMessage("invalid token").Wrap(err)
+	}
+	SetClaims(c, claims)
+	return nil
+}
@@ -0,0 +1,302 @@
+package ginx
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CompressConfig compression middleware config
+type CompressConfig struct {
+	Level         int      // compression level, default gzip.DefaultCompression
+	MinLength     int      // smaller responses are not compressed, default 1024
+	ExcludedTypes []string // content type prefixes never compressed, added to the defaults
+	MaxDecoded    int64    // max decoded request body bytes, default 10MB
+}
+
+// defaultExcludedTypes already compressed or streamed content types
+var defaultExcludedTypes = []string{
+	"image/", "video/", "audio/", "font/woff",
+	"application/zip", "application/gzip", "application/x-gzip", "application/x-7z-compressed",
+	"application/x-rar-compressed", "application/pdf", "application/octet-stream",
+	"text/event-stream",
+}
+
+// Compress gzip or deflate the responses accepted so by the client, and decode
+// request bodies sent with Content-Encoding gzip or deflate
+// already compressed types, streams and responses under MinLength are sent as is,
+// a decoded request body over MaxDecoded fails with 413
+func Compress(cfg CompressConfig) gin.HandlerFunc {
+	if cfg.Level == 0 {
+		cfg.Level = gzip.DefaultCompression
+	}
+	if cfg.MinLength <= 0 {
+		cfg.MinLength = 1024
+	}
+	if cfg.MaxDecoded <= 0 {
+		cfg.MaxDecoded = 10 << 20
+	}
+	if _, err := gzip.NewWriterLevel(io.Discard, cfg.Level); err != nil {
+		panic(err)
+	}
+	excluded := append(append([]string(nil), defaultExcludedTypes...), cfg.ExcludedTypes...)
+	pools := map[string]*sync.Pool{
+		"gzip": {New: func() any {
+			w, _ := gzip.NewWriterLevel(io.Discard, cfg.Level)
+			return w
+		}},
+		"deflate": {New: func() any {
+			w, _ := zlib.NewWriterLevel(io.Discard, cfg.Level)
+			return w
+		}},
+	}
+
+	return func(c *gin.Context) {
+		if err := decodeRequest(c, cfg.MaxDecoded); err != nil {
+			Fail(c, err)
+			return
+		}
+		encoding := acceptEncoding(c.GetHeader("Accept-Encoding"))
+		if encoding == "" || c.Request.Method == http.MethodHead ||
+			c.GetHeader("Upgrade") != "" || strings.Contains(c.GetHeader("Accept"), "text/event-stream") {
+			c.Next()
+			return
+		}
+		c.Writer.Header().Add("Vary", "Accept-Encoding")
+		w := &compressWriter{ResponseWriter: c.Writer, encoding: encoding, pool: pools[encoding], min: cfg.MinLength, excluded: excluded}
+		c.Writer = w
+		defer func() {
+			if c.Writer == gin.ResponseWriter(w) {
+				c.Writer = w.ResponseWriter
+			}
+			w.close()
+		}()
+		c.Next()
+	}
+}
+
+// compressor gzip.Writer and zlib.Writer, http deflate is the zlib format
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// acceptEncoding the preferred supported encoding, gzip before deflate
+func acceptEncoding(header string) string {
+	accepted := make(map[string]bool)
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			q, _ = strconv.ParseFloat(v, 64)
+		}
+		accepted[strings.ToLower(strings.TrimSpace(name))] = q > 0
+	}
+	for _, encoding := range []string{"gzip", "deflate"} {
+		if accepted[encoding] || accepted["*"] && !hasKey(accepted, encoding) {
+			return encoding
+		}
+	}
+	return ""
+}
+
+// hasKey report whether the key is in the map
+func hasKey(m map[string]bool, key string) bool {
+	_, ok := m[key]
+	return ok
+}
+
+// compressWriter buffer the first MinLength bytes, then compress or pass through
+type compressWriter struct {
+	gin.ResponseWriter
+	encoding string
+	pool     *sync.Pool
+	min      int
+	excluded []string
+
+	buf     bytes.Buffer
+	decided bool
+	cw      compressor // nil when passing through
+}
+
+// Unwrap the wrapped writer, for http.ResponseController
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// decide compress or pass through, from the response headers
+func (w *compressWriter) decide(compress bool) {
+	w.decided = true
+	h := w.ResponseWriter.Header()
+	status := w.ResponseWriter.Status()
+	if !compress || h.Get("Content-Encoding") != "" || status < http.StatusOK ||
+		status == http.StatusNoContent || status == http.StatusNotModified || w.isExcluded(h.Get("Content-Type")) {
+		return
+	}
+	w.cw = w.pool.Get().(compressor)
+	w.cw.Reset(w.ResponseWriter)
+	h.Set("Content-Encoding", w.encoding)
+	h.Del("Content-Length")
+}
+
+// isExcluded report whether the content type is excluded
+func (w *compressWriter) isExcluded(contentType string) bool {
+	contentType = strings.ToLower(contentType)
+	for _, prefix := range w.excluded {
+		if strings.HasPrefix(contentType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Write buffer, compress or pass through
+func (w *compressWriter) Write(p []byte) (int, error) {
+	if !w.decided {
+		if w.buf.Len()+len(p) < w.min {
+			return w.buf.Write(p)
+		}
+		if w.ResponseWriter.Header().Get("Content-Type") == "" {
+			w.ResponseWriter.Header().Set("Content-Type", http.DetectContentType(append(w.buf.Bytes(), p...)))
+		}
+		w.decide(true)
+		if err := w.drain(); err != nil {
+			return 0, err
+		}
+	}
+	if w.cw != nil {
+		return w.cw.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// WriteString write the string
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// drain write the buffered bytes
+func (w *compressWriter) drain() error {
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	var err error
+	if w.cw != nil {
+		_, err = w.cw.Write(w.buf.Bytes())
+	} else {
+		_, err = w.ResponseWriter.Write(w.buf.Bytes())
+	}
+	w.buf.Reset()
+	return err
+}
+
+// Written report whether the body was written
+func (w *compressWriter) Written() bool {
+	return w.buf.Len() > 0 || w.ResponseWriter.Written()
+}
+
+// WriteHeaderNow the header is written with the body
+func (w *compressWriter) WriteHeaderNow() {
+	if w.decided {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+// Flush a flushed response is a stream, it is compressed only if it already was
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		w.decide(false)
+		_ = w.drain()
+	}
+	if w.cw != nil {
+		_ = w.cw.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// close write the small responses as is and finish the compressed stream
+func (w *compressWriter) close() {
+	if !w.decided {
+		w.decide(false)
+		if w.buf.Len() == 0 {
+			w.ResponseWriter.WriteHeaderNow()
+			return
+		}
+		_ = w.drain()
+		return
+	}
+	if w.cw != nil {
+		_ = w.cw.Close()
+		w.cw.Reset(io.Discard)
+		w.pool.Put(w.cw)
+		w.cw = nil
+	}
+}
+
+// decodeRequest decode the gzip or deflate request body
+func decodeRequest(c *gin.Context, max int64) error {
+	encoding := strings.ToLower(strings.TrimSpace(c.GetHeader("Content-Encoding")))
+	if encoding == "" || encoding == "identity" || c.Request.Body == nil || c.Request.Body == http.NoBody {
+		return nil
+	}
+	var r io.ReadCloser
+	switch encoding {
+	case "gzip", "x-gzip":
+		gr, err := gzip.NewReader(c.Request.Body)
+		if err != nil {
+			return ErrBadRequest.WithMessage("invalid gzip body").Wrap(err)
+		}
+		r = gr
+	case "deflate":
+		zr, err := zlib.NewReader(c.Request.Body)
+		if err != nil {
+			return ErrBadRequest.WithMessage("invalid deflate body").Wrap(err)
+		}
+		r = zr
+	default:
+		return NewBizError(41500, http.StatusUnsupportedMediaType, "unsupported_encoding", "unsupported content encoding "+encoding)
+	}
+	c.Request.Body = &decodedBody{r: r, raw: c.Request.Body, left: max, max: max}
+	c.Request.Header.Del("Content-Encoding")
+	c.Request.Header.Del("Content-Length")
+	c.Request.ContentLength = -1
+	return nil
+}
+
+// decodedBody decoded request body limited to max bytes
+type decodedBody struct {
+	r    io.ReadCloser
+	raw  io.ReadCloser
+	left int64
+	max  int64
+}
+
+// Read read decoded bytes, a body over the limit fails with *http.MaxBytesError
+func (b *decodedBody) Read(p []byte) (int, error) {
+	if b.left < 0 {
+		return 0, &http.MaxBytesError{Limit: b.max}
+	}
+	if int64(len(p)) > b.left+1 {
+		p = p[:b.left+1]
+	}
+	n, err := b.r.Read(p)
+	b.left -= int64(n)
+	if b.left < 0 {
+		return n - int(-b.left), &http.MaxBytesError{Limit: b.max}
+	}
+	return n, err
+}
+
+// Close close the decoder and the raw body
+func (b *decodedBody) Close() error {
+	b.r.Close()
+	return b.raw.Close()
+}
@@ -0,0 +1,100 @@
+package ginx
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCompress(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	payload := strings.Repeat("ginx compress ", 200)
+	e := gin.New()
+	e.Use(Compress(CompressConfig{}))
+	e.POST("/echo", func(c *gin.Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			Fail(c, ErrBadRequest.Wrap(err))
+			return
+		}
+		c.String(http.StatusOK, string(body))
+	})
+
+	encode := map[string]func(w io.Writer) io.WriteCloser{
+		"gzip":    func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) },
+		"deflate": func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) },
+	}
+	decode := map[string]func(r io.Reader) (io.Reader, error){
+		"gzip":    func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		"deflate": func(r io.Reader) (io.Reader, error) { return zlib.NewReader(r) },
+	}
+	tests := []struct {
+		name    string
+		request string // Content-Encoding of the request
+		accept  string
+		status  int
+		want    string // Content-Encoding of the response
+	}{
+		{"gzip", "gzip", "gzip", http.StatusOK, "gzip"},
+		{"deflate is zlib", "deflate", "deflate", http.StatusOK, "deflate"},
+		{"plain request, deflate response", "", "deflate, br", http.StatusOK, "deflate"},
+		{"identity", "", "", http.StatusOK, ""},
+		{"raw deflate request", "raw", "", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body bytes.Buffer
+			switch tt.request {
+			case "":
+				body.WriteString(payload)
+			case "raw":
+				// a raw deflate stream without the zlib header
+				body.WriteString("\x4b\xcf\xcc\xab\x00\x00")
+			default:
+				zw := encode[tt.request](&body)
+				_, _ = zw.Write([]byte(payload))
+				_ = zw.Close()
+			}
+			req := httptest.NewRequest(http.MethodPost, "/echo", &body)
+			switch tt.request {
+			case "raw":
+				req.Header.Set("Content-Encoding", "deflate")
+			case "":
+			default:
+				req.Header.Set("Content-Encoding", tt.request)
+			}
+			if tt.accept != "" {
+				req.Header.Set("Accept-Encoding", tt.accept)
+			}
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, req)
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			if got := w.Header().Get("Content-Encoding"); got != tt.want {
+				t.Fatalf("Content-Encoding %q, want %q", got, tt.want)
+			}
+			var r io.Reader = w.Body
+			if tt.want != "" {
+				var err error
+				if r, err = decode[tt.want](w.Body); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := io.ReadAll(r)
+			if err != nil || string(got) != payload {
+				t.Fatalf("body %q, %v", got, err)
+			}
+		})
+	}
+}
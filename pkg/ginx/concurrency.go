@@ -0,0 +1,297 @@
+package ginx
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// AIMDConfig additive increase, multiplicative decrease of the concurrency limit
+// a request faster than Latency adds 1/limit, so the limit grows by 1 per limit fast requests;
+// a slower, 5xx or expired request multiplies the limit by Backoff, at most once per Latency
+type AIMDConfig struct {
+	Latency  time.Duration // target latency, required
+	MinLimit int           // default 1
+	MaxLimit int           // default 4 times the initial limit
+	Backoff  float64       // decrease factor, default 0.9
+}
+
+// ConcurrencyConfig concurrency limiter config
+type ConcurrencyConfig struct {
+	Name         string        // limiter name in the logs and metrics, default limiterN
+	Limit        int           // max requests running at once, the initial limit when Adaptive, required
+	Queue        int           // max requests waiting for a slot, 0 rejects at once when the limit is reached
+	QueueTimeout time.Duration // max wait in the queue, default 1s, the request deadline is honored too
+	Adaptive     *AIMDConfig   // adapt the limit to the observed latency, nil keeps it fixed
+}
+
+// ConcurrencyLimiter limit the requests running at once, the others wait in a bounded FIFO queue
+// requests that can not get a slot are shed with 503 and Retry-After,
+// counted in the metrics and logged through pkg/log;
+// the limit, in-flight and queued gauges are exposed by the metrics of the app it is added to
+type ConcurrencyLimiter struct {
+	cfg      ConcurrencyConfig
+	mu       sync.Mutex
+	limit    float64
+	inFlight int
+	waiters  *list.List // chan struct{} closed when the slot is granted
+	lastDrop time.Time
+}
+
+var limiterSeq uint64
+
+// NewConcurrencyLimiter create a concurrency limiter
+func NewConcurrencyLimiter(cfg ConcurrencyConfig) *ConcurrencyLimiter {
+	if cfg.Limit <= 0 {
+		panic("concurrency limiter needs a positive Limit")
+	}
+	if cfg.Queue < 0 {
+		cfg.Queue = 0
+	}
+	if cfg.QueueTimeout <= 0 {
+		cfg.QueueTimeout = time.Second
+	}
+	if cfg.Name == "" {
+		cfg.Name = "limiter" + strconv.FormatUint(atomic.AddUint64(&limiterSeq, 1), 10)
+	}
+	if a := cfg.Adaptive; a != nil {
+		if a.Latency <= 0 {
+			panic("adaptive concurrency limit needs a positive Latency")
+		}
+		aimd := *a
+		if aimd.MinLimit <= 0 {
+			aimd.MinLimit = 1
+		}
+		if aimd.MaxLimit <= 0 {
+			aimd.MaxLimit = 4 * cfg.Limit
+		}
+		if aimd.Backoff <= 0 || aimd.Backoff >= 1 {
+			aimd.Backoff = 0.9
+		}
+		cfg.Adaptive = &aimd
+	}
+	return &ConcurrencyLimiter{cfg: cfg, limit: float64(cfg.Limit), waiters: list.New()}
+}
+
+// AddLimiters expose the gauges of the limiters in the app metrics
+func (a *App) AddLimiters(limiters ...*ConcurrencyLimiter) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.limiters = append(a.limiters, limiters...)
+}
+
+// AddLimiters expose the gauges of the limiters in the singleton app metrics
+func AddLimiters(limiters ...*ConcurrencyLimiter) {
+	check()
+	this.AddLimiters(limiters...)
+}
+
+// Middleware limit the requests of the routes using it, routes sharing the limiter share the slots
+func (l *ConcurrencyLimiter) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if reason := l.acquire(c.Request.Context()); reason != "" {
+			countShed(l.cfg.Name, reason)
+			logger().Warn("request shed",
+				zap.String("limiter", l.cfg.Name),
+				zap.String("reason", reason),
+				zap.String("method", c.Request.Method),
+				zap.String("path", c.Request.URL.Path),
+				zap.Int("limit", l.Limit()))
+			c.Header("Retry-After", "1")
+			Fail(c, ErrServiceUnavailable)
+			return
+		}
+		start := time.Now()
+		defer func() {
+			failed := c.Writer.Status() >= http.StatusInternalServerError ||
+				errors.Is(c.Request.Context().Err(), context.DeadlineExceeded)
+			l.release(time.Since(start), failed)
+		}()
+		c.Next()
+	}
+}
+
+// acquire take a slot, or return the rejection reason
+func (l *ConcurrencyLimiter) acquire(ctx context.Context) string {
+	l.mu.Lock()
+	if l.inFlight < l.current() && l.waiters.Len() == 0 {
+		l.inFlight++
+		l.mu.Unlock()
+		return ""
+	}
+	if l.waiters.Len() >= l.cfg.Queue {
+		l.mu.Unlock()
+		return "queue_full"
+	}
+	granted := make(chan struct{})
+	el := l.waiters.PushBack(granted)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(l.cfg.QueueTimeout)
+	defer timer.Stop()
+	reason := ""
+	select {
+	case <-granted:
+		return ""
+	case <-timer.C:
+		reason = "queue_timeout"
+	case <-ctx.Done():
+		reason = "canceled"
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			reason = "deadline"
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	select {
+	case <-granted:
+		// granted while giving up, hand the slot to the next waiter
+		l.inFlight--
+		l.grant()
+	default:
+		l.waiters.Remove(el)
+	}
+	return reason
+}
+
+// release free the slot, adapt the limit and grant the waiters
+func (l *ConcurrencyLimiter) release(latency time.Duration, failed bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inFlight--
+	if a := l.cfg.Adaptive; a != nil {
+		now := time.Now()
+		switch {
+		case failed || latency > a.Latency:
+			if now.Sub(l.lastDrop) >= a.Latency {
+				l.limit = math.Max(float64(a.MinLimit), l.limit*a.Backoff)
+				l.lastDrop = now
+			}
+		case l.inFlight+1 >= l.current():
+			// grow only when the limit is actually reached
+			l.limit = math.Min(float64(a.MaxLimit), l.limit+1/l.limit)
+		}
+	}
+	l.grant()
+}
+
+// grant wake the waiters the limit allows, l.mu is held
+func (l *ConcurrencyLimiter) grant() {
+	for l.inFlight < l.current() && l.waiters.Len() > 0 {
+		granted := l.waiters.Remove(l.waiters.Front()).(chan struct{})
+		l.inFlight++
+		close(granted)
+	}
+}
+
+// current the limit in whole requests, l.mu is held
+func (l *ConcurrencyLimiter) current() int {
+	return int(l.limit)
+}
+
+// Limit the current limit
+func (l *ConcurrencyLimiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.current()
+}
+
+// InFlight requests running now
+func (l *ConcurrencyLimiter) InFlight() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inFlight
+}
+
+// Queued requests waiting for a slot
+func (l *ConcurrencyLimiter) Queued() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.waiters.Len()
+}
+
+// shedKey source and reason of shed requests
+type shedKey struct {
+	source string // limiter name or timeout
+	reason string
+}
+
+var (
+	shed   = make(map[shedKey]uint64)
+	shedMu sync.Mutex
+)
+
+// countShed count a request rejected by a limiter or a timeout
+func countShed(source, reason string) {
+	shedMu.Lock()
+	defer shedMu.Unlock()
+	shed[shedKey{source, reason}]++
+}
+
+// shedCollector requests shed by the limiters and timeouts
+type shedCollector struct{}
+
+// WriteMetrics implement Collector
+func (shedCollector) WriteMetrics(w io.Writer, namespace string) {
+	shedMu.Lock()
+	counts := make(map[shedKey]uint64, len(shed))
+	keys := make([]shedKey, 0, len(shed))
+	for k, v := range shed {
+		counts[k] = v
+		keys = append(keys, k)
+	}
+	shedMu.Unlock()
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].source != keys[j].source {
+			return keys[i].source < keys[j].source
+		}
+		return keys[i].reason < keys[j].reason
+	})
+	name := namespace + "_shed_requests_total"
+	writeHeader(w, name, "counter", "Requests rejected by the concurrency limiters and timeouts per source and reason.")
+	for _, k := range keys {
+		fmt.Fprintf(w, "%s{source=\"%s\",reason=\"%s\"} %d\n", name, escapeLabel(k.source), escapeLabel(k.reason), counts[k])
+	}
+}
+
+// limiterCollector state of the limiters added to the app
+type limiterCollector struct {
+	app *App
+}
+
+// WriteMetrics implement Collector
+func (lc limiterCollector) WriteMetrics(w io.Writer, namespace string) {
+	lc.app.mu.Lock()
+	ls := append([]*ConcurrencyLimiter(nil), lc.app.limiters...)
+	lc.app.mu.Unlock()
+	if len(ls) == 0 {
+		return
+	}
+	for _, g := range []struct {
+		metric, help string
+		value        func(l *ConcurrencyLimiter) int
+	}{
+		{"limit", "Current concurrency limit.", (*ConcurrencyLimiter).Limit},
+		{"in_flight", "Requests holding a concurrency slot.", (*ConcurrencyLimiter).InFlight},
+		{"queued", "Requests waiting for a concurrency slot.", (*ConcurrencyLimiter).Queued},
+	} {
+		metric := namespace + "_concurrency_" + g.metric
+		writeHeader(w, metric, "gauge", g.help)
+		for _, l := range ls {
+			fmt.Fprintf(w, "%s{limiter=\"%s\"} %d\n", metric, escapeLabel(l.cfg.Name), g.value(l))
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package ginx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestConcurrencyLimiter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	limiter := NewConcurrencyLimiter(ConcurrencyConfig{Name: "test", Limit: 2, Queue: 2, QueueTimeout: time.Second})
+	release := make(chan struct{})
+	e := gin.New()
+	e.GET("/", limiter.Middleware(), func(c *gin.Context) {
+		<-release
+		c.Status(http.StatusOK)
+	})
+
+	codes := make(chan int, 8)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+			codes <- w.Code
+		}()
+	}
+	// 2 running, 2 queued, the others are shed at once
+	for i := 0; i < 4; i++ {
+		if code := <-codes; code != http.StatusServiceUnavailable {
+			t.Fatalf("shed request status %d", code)
+		}
+	}
+	close(release)
+	wg.Wait()
+	close(codes)
+	for code := range codes {
+		if code != http.StatusOK {
+			t.Fatalf("admitted request status %d", code)
+		}
+	}
+	if limiter.InFlight() != 0 || limiter.Queued() != 0 {
+		t.Fatalf("in flight %d, queued %d after the requests", limiter.InFlight(), limiter.Queued())
+	}
+}
+
+func TestLimiterMetricsPerApp(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	a, b := New(nil), New(nil)
+	a.AddLimiters(NewConcurrencyLimiter(ConcurrencyConfig{Name: "only_a", Limit: 1}))
+	for _, tt := range []struct {
+		app  *App
+		want bool
+	}{{a, true}, {b, false}} {
+		tt.app.EnableMetrics("", MetricsConfig{})
+		w := httptest.NewRecorder()
+		tt.app.Engine().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		if got := strings.Contains(w.Body.String(), `ginx_concurrency_limit{limiter="only_a"} 1`); got != tt.want {
+			t.Fatalf("limiter gauge exposed %v, want %v:\n%s", got, tt.want, w.Body.String())
+		}
+	}
+}
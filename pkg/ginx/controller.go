@@ -0,0 +1,362 @@
+package ginx
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Route controller route table item
+type Route struct {
+	Method     string            // http method, use Any for all methods
+	Path       string            // path relative to the controller prefix
+	Handler    gin.HandlerFunc   // route handler
+	Middleware []gin.HandlerFunc // route middleware
+}
+
+// Any route method matching every http method
+const Any = "ANY"
+
+// RouteTabler controller returning its own route table
+// the naming convention and struct tags are not used when implemented
+type RouteTabler interface {
+	RouteTable() []Route
+}
+
+// ControllerPrefix controller with a path prefix
+type ControllerPrefix interface {
+	Prefix() string
+}
+
+// ControllerMiddleware controller with middleware for all its routes
+type ControllerMiddleware interface {
+	Middleware() []gin.HandlerFunc
+}
+
+// methodPrefixes method name prefix to http method
+var methodPrefixes = []struct {
+	prefix string
+	method string
+}{
+	{"Get", http.MethodGet},
+	{"Head", http.MethodHead},
+	{"Options", http.MethodOptions},
+	{"Patch", http.MethodPatch},
+	{"Post", http.MethodPost},
+	{"Put", http.MethodPut},
+	{"Delete", http.MethodDelete},
+	{"Any", Any},
+}
+
+var handlerType = reflect.TypeOf(gin.HandlerFunc(nil))
+
+// controllerRoute route discovered on a controller
+type controllerRoute struct {
+	Route
+	owner string // demo: UserController.GetUser
+}
+
+// controllerRouter adapt discovered controller routes to Router
+type controllerRouter struct {
+	ctrl   any
+	name   string
+	prefix string
+	routes []controllerRoute
+	mw     []gin.HandlerFunc
+}
+
+// Execute register the controller routes
+func (r *controllerRouter) Execute(c *gin.Engine) {
+	g := c.Group(r.prefix, r.mw...)
+	for _, route := range r.routes {
+		handlers := append(append([]gin.HandlerFunc{}, route.Middleware...), route.Handler)
+		if route.Method == Any {
+			g.Any(route.Path, handlers...)
+			continue
+		}
+		g.Handle(route.Method, route.Path, handlers...)
+	}
+}
+
+// entries absolute route entries of the controller
+func (r *controllerRouter) entries() []routeEntry {
+	result := make([]routeEntry, 0, len(r.routes))
+	for _, route := range r.routes {
+		abs := joinPath(r.prefix, route.Path)
+		methods := []string{route.Method}
+		if route.Method == Any {
+			methods = anyMethods
+		}
+		for _, method := range methods {
+			result = append(result, routeEntry{method: method, path: abs, owner: route.owner})
+		}
+	}
+	return result
+}
+
+// anyMethods methods registered by gin for Any
+var anyMethods = []string{
+	http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch,
+	http.MethodHead, http.MethodOptions, http.MethodDelete, http.MethodConnect,
+	http.MethodTrace,
+}
+
+// AddControllers discover the handler methods of the controllers and add them as routers
+// routes come from RouteTable, from `route:"GET /path"` tags on handler fields
+// or from the method names: GetUser -> GET /user, DeleteUserByID -> DELETE /user/:id
+// duplicate routes are reported here, the other conflicts by RouterExecuteE
+func (a *App) AddControllers(controllers ...any) error {
+	routers := make([]*controllerRouter, 0, len(controllers))
+	entries := make([]routeEntry, 0)
+	for _, ctrl := range controllers {
+		router, err := parseController(ctrl)
+		if err != nil {
+			return err
+		}
+		routers = append(routers, router)
+		entries = append(entries, router.entries()...)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	seen := make(map[string]routeEntry)
+	for _, entry := range append(a.controllerEntries(), entries...) {
+		key := entry.method + " " + routeShape(entry.path)
+		if k, ok := seen[key]; ok {
+			return fmt.Errorf("route %s conflicts with %s", entry, k)
+		}
+		seen[key] = entry
+	}
+	for _, router := range routers {
+		a.routers = append(a.routers, router)
+	}
+	return nil
+}
+
+// routeShape path with the param names dropped, routes of the same shape always conflict in gin
+func routeShape(p string) string {
+	segments := strings.Split(p, "/")
+	for i, s := range segments {
+		if strings.HasPrefix(s, ":") || strings.HasPrefix(s, "*") {
+			segments[i] = s[:1]
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// controllerEntries route entries of the controllers already added, a.mu is held
+func (a *App) controllerEntries() []routeEntry {
+	result := make([]routeEntry, 0)
+	for _, router := range a.routers {
+		if cr, ok := router.(*controllerRouter); ok {
+			result = append(result, cr.entries()...)
+		}
+	}
+	return result
+}
+
+// AddControllers add controllers to the singleton app
+func AddControllers(controllers ...any) error {
+	check()
+	return this.AddControllers(controllers...)
+}
+
+// parseController build the controller router
+func parseController(ctrl any) (*controllerRouter, error) {
+	if ctrl == nil {
+		return nil, errors.New("controller is nil")
+	}
+	v := reflect.ValueOf(ctrl)
+	name := reflect.Indirect(v).Type().Name()
+	router := &controllerRouter{ctrl: ctrl, name: name}
+	if p, ok := ctrl.(ControllerPrefix); ok {
+		router.prefix = p.Prefix()
+	}
+	if m, ok := ctrl.(ControllerMiddleware); ok {
+		router.mw = m.Middleware()
+	}
+
+	if t, ok := ctrl.(RouteTabler); ok {
+		for i, route := range t.RouteTable() {
+			if route.Handler == nil {
+				return nil, fmt.Errorf("%s route table item %d has no handler", name, i)
+			}
+			route.Method = strings.ToUpper(route.Method)
+			router.routes = append(router.routes, controllerRoute{Route: route, owner: fmt.Sprintf("%s[%d]", name, i)})
+		}
+		return router, nil
+	}
+
+	routes, err := tagRoutes(v, name)
+	if err != nil {
+		return nil, err
+	}
+	router.routes = append(routes, methodRoutes(v, name)...)
+	if len(router.routes) == 0 {
+		return nil, fmt.Errorf("%s has no handler method", name)
+	}
+	return router, nil
+}
+
+// tagRoutes routes from handler fields tagged with `route:"METHOD /path"`
+func tagRoutes(v reflect.Value, name string) ([]controllerRoute, error) {
+	result := make([]controllerRoute, 0)
+	sv := reflect.Indirect(v)
+	if sv.Kind() != reflect.Struct {
+		return result, nil
+	}
+	st := sv.Type()
+	for i := 0; i < st.NumField(); i++ {
+		field := st.Field(i)
+		tag, ok := field.Tag.Lookup("route")
+		if !ok || !field.IsExported() {
+			continue
+		}
+		if !field.Type.ConvertibleTo(handlerType) {
+			return nil, fmt.Errorf("%s.%s is tagged as route but is not a gin handler", name, field.Name)
+		}
+		method, path, found := strings.Cut(strings.TrimSpace(tag), " ")
+		if !found {
+			return nil, fmt.Errorf("%s.%s route tag %q should be like \"GET /path\"", name, field.Name, tag)
+		}
+		fv := sv.Field(i)
+		if fv.IsNil() {
+			return nil, fmt.Errorf("%s.%s is nil", name, field.Name)
+		}
+		result = append(result, controllerRoute{
+			Route: Route{
+				Method:  strings.ToUpper(method),
+				Path:    strings.TrimSpace(path),
+				Handler: fv.Convert(handlerType).Interface().(gin.HandlerFunc),
+			},
+			owner: name + "." + field.Name,
+		})
+	}
+	return result, nil
+}
+
+// methodRoutes routes from the exported handler method names
+func methodRoutes(v reflect.Value, name string) []controllerRoute {
+	result := make([]controllerRoute, 0)
+	t := v.Type()
+	for i := 0; i < t.NumMethod(); i++ {
+		m := t.Method(i)
+		mv := v.Method(i)
+		if !mv.Type().ConvertibleTo(handlerType) {
+			continue
+		}
+		method, path, ok := routeFromName(m.Name)
+		if !ok {
+			continue
+		}
+		result = append(result, controllerRoute{
+			Route: Route{
+				Method:  method,
+				Path:    path,
+				Handler: mv.Convert(handlerType).Interface().(gin.HandlerFunc),
+			},
+			owner: name + "." + m.Name,
+		})
+	}
+	return result
+}
+
+// routeFromName parse the method name
+// GetUser -> GET /user, GetUserProfile -> GET /user-profile
+// GetUserByID -> GET /user/:id, GetOrderByUserIDAndID -> GET /order/:userId/:id
+// Get -> GET on the controller prefix
+func routeFromName(name string) (method, path string, ok bool) {
+	for _, p := range methodPrefixes {
+		rest := strings.TrimPrefix(name, p.prefix)
+		if rest == name || (rest != "" && !unicode.IsUpper(rune(rest[0]))) {
+			continue
+		}
+		words := splitCamel(rest)
+		static, params := words, []string(nil)
+		for i, w := range words {
+			if w == "By" && i+1 < len(words) {
+				static, params = words[:i], words[i+1:]
+				break
+			}
+		}
+
+		segments := make([]string, 0)
+		if len(static) > 0 {
+			segments = append(segments, strings.ToLower(strings.Join(static, "-")))
+		}
+		param := make([]string, 0)
+		for i, w := range params {
+			if w == "And" || i == len(params)-1 {
+				if w != "And" {
+					param = append(param, w)
+				}
+				if len(param) > 0 {
+					segments = append(segments, ":"+lowerCamel(param))
+				}
+				param = param[:0]
+				continue
+			}
+			param = append(param, w)
+		}
+		if len(segments) == 0 {
+			return p.method, "", true
+		}
+		return p.method, "/" + strings.Join(segments, "/"), true
+	}
+	return "", "", false
+}
+
+// splitCamel split camel case words, acronyms stay together: HTTPStatus -> HTTP Status
+func splitCamel(s string) []string {
+	words := make([]string, 0)
+	runes := []rune(s)
+	start := 0
+	for i := 1; i < len(runes); i++ {
+		upper := unicode.IsUpper(runes[i])
+		if upper && (!unicode.IsUpper(runes[i-1]) || (i+1 < len(runes) && !unicode.IsUpper(runes[i+1]))) {
+			words = append(words, string(runes[start:i]))
+			start = i
+		}
+	}
+	if start < len(runes) {
+		words = append(words, string(runes[start:]))
+	}
+	return words
+}
+
+// lowerCamel join words as lower camel case: User ID -> userId
+func lowerCamel(words []string) string {
+	var b strings.Builder
+	for i, w := range words {
+		w = strings.ToLower(w)
+		if i > 0 {
+			w = strings.ToUpper(w[:1]) + w[1:]
+		}
+		b.WriteString(w)
+	}
+	return b.String()
+}
+
+// joinPath absolute path of a route registered with path on a group created with prefix,
+// with the path.Join semantics of gin: a trailing slash of the relative path is kept
+func joinPath(prefix, p string) string {
+	return joinPaths(joinPaths("/", prefix), p)
+}
+
+// joinPaths join like gin.RouterGroup does for BasePath and the route paths
+func joinPaths(absolute, relative string) string {
+	if relative == "" {
+		return absolute
+	}
+	result := path.Join(absolute, relative)
+	if strings.HasSuffix(relative, "/") && !strings.HasSuffix(result, "/") {
+		return result + "/"
+	}
+	return result
+}
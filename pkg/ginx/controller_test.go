@@ -0,0 +1,116 @@
+package ginx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRouteFromName(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		ok     bool
+	}{
+		{"GetUser", http.MethodGet, "/user", true},
+		{"GetUserByID", http.MethodGet, "/user/:id", true},
+		{"GetUserProfile", http.MethodGet, "/user-profile", true},
+		{"DeleteUserByID", http.MethodDelete, "/user/:id", true},
+		{"GetOrderByUserIDAndID", http.MethodGet, "/order/:userId/:id", true},
+		{"PostHTTPStatus", http.MethodPost, "/http-status", true},
+		{"AnyPing", Any, "/ping", true},
+		{"Get", http.MethodGet, "", true},
+		{"Getaway", "", "", false},
+		{"Helper", "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method, path, ok := routeFromName(tt.name)
+			if method != tt.method || path != tt.path || ok != tt.ok {
+				t.Fatalf("routeFromName = %s %q %v, want %s %q %v", method, path, ok, tt.method, tt.path, tt.ok)
+			}
+		})
+	}
+}
+
+type userController struct {
+	Export gin.HandlerFunc `route:"GET /export"`
+}
+
+func (userController) Prefix() string { return "/users" }
+
+func (userController) GetByID(c *gin.Context) { c.String(http.StatusOK, "user "+c.Param("id")) }
+
+func (userController) PostProfile(c *gin.Context) { c.String(http.StatusCreated, "profile") }
+
+type clashController struct{}
+
+func (clashController) Prefix() string { return "/users" }
+
+func (clashController) GetByUserID(c *gin.Context) {}
+
+type tableController struct{}
+
+func (tableController) RouteTable() []Route {
+	return []Route{{Method: "get", Path: "/health", Handler: func(c *gin.Context) { c.String(http.StatusOK, "up") }}}
+}
+
+func TestAddControllers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	a := New(nil)
+	ctrl := userController{Export: func(c *gin.Context) { c.String(http.StatusOK, "csv") }}
+	if err := a.AddControllers(ctrl, tableController{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.RouterExecuteE(); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		method string
+		path   string
+		status int
+		body   string
+	}{
+		{http.MethodGet, "/users/42", http.StatusOK, "user 42"},
+		{http.MethodPost, "/users/profile", http.StatusCreated, "profile"},
+		{http.MethodGet, "/users/export", http.StatusOK, "csv"},
+		{http.MethodGet, "/health", http.StatusOK, "up"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		a.Engine().ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+		if w.Code != tt.status || w.Body.String() != tt.body {
+			t.Fatalf("%s %s: %d %q, want %d %q", tt.method, tt.path, w.Code, w.Body.String(), tt.status, tt.body)
+		}
+	}
+	for _, route := range a.Routes() {
+		if route.Path == "/users/:id" && route.Owner != "userController.GetByID" {
+			t.Fatalf("owner of %s %s is %q", route.Method, route.Path, route.Owner)
+		}
+	}
+}
+
+func TestAddControllersConflict(t *testing.T) {
+	tests := []struct {
+		name        string
+		controllers []any
+		err         string // part of the error, empty for none
+	}{
+		{"same shape, other param name", []any{userController{Export: func(*gin.Context) {}}, clashController{}}, "GET /users/:userId (clashController.GetByUserID) conflicts with GET /users/:id (userController.GetByID)"},
+		{"nil tagged handler", []any{userController{}}, "userController.Export is nil"},
+		{"no handler", []any{struct{}{}}, "has no handler method"},
+		{"nil controller", []any{nil}, "controller is nil"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := New(nil).AddControllers(tt.controllers...)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,317 @@
+package ginx
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CORSConfig cross-origin resource sharing policy
+type CORSConfig struct {
+	AllowOrigins     []string           // exact origins, wildcard subdomains or *, demo: https://a.com, https://*.a.com
+	AllowOriginRegex []string           // origin regular expressions, demo: ^https://pr-\d+\.preview\.a\.com$
+	AllowOriginFunc  func(string) bool  // custom origin check
+	AllowMethods     []string           // default GET, POST, PUT, PATCH, DELETE, HEAD
+	AllowHeaders     []string           // request headers allowed, default Origin, Accept, Content-Type, Authorization, X-Request-ID
+	ExposeHeaders    []string           // response headers readable by the browser
+	AllowCredentials bool               // allow cookies and authorization headers
+	MaxAge           time.Duration      // how long the preflight result can be cached, 0 no header
+	OnReject         func(*gin.Context) // called for a disallowed origin, and before a rejected preflight is answered with 403
+}
+
+var (
+	defaultCORSMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodHead}
+	defaultCORSHeaders = []string{"Origin", "Accept", "Content-Type", "Authorization", RequestIDHeader}
+)
+
+// corsPolicy compiled CORSConfig
+type corsPolicy struct {
+	cfg       CORSConfig
+	any       bool             // * origin
+	exact     map[string]bool  // lower case origins
+	wildcards []corsWildcard   // wildcard subdomain origins
+	regexps   []*regexp.Regexp // origin regular expressions
+	methods   map[string]bool  // allowed methods
+	headers   map[string]bool  // allowed request headers in canonical form, nil if any
+	allow     [3]string        // Allow-Methods, Allow-Headers and Expose-Headers values
+	maxAge    string           // Max-Age value
+}
+
+// corsWildcard demo: https://*.a.com is scheme https:// and suffix .a.com
+type corsWildcard struct {
+	scheme string
+	suffix string
+}
+
+// newCORSPolicy validate and compile the config
+func newCORSPolicy(cfg CORSConfig) (*corsPolicy, error) {
+	if len(cfg.AllowOrigins) == 0 && len(cfg.AllowOriginRegex) == 0 && cfg.AllowOriginFunc == nil {
+		return nil, errors.New("cors: no allowed origin")
+	}
+	if len(cfg.AllowMethods) == 0 {
+		cfg.AllowMethods = defaultCORSMethods
+	}
+	if len(cfg.AllowHeaders) == 0 {
+		cfg.AllowHeaders = defaultCORSHeaders
+	}
+	p := &corsPolicy{cfg: cfg, exact: make(map[string]bool), methods: make(map[string]bool), headers: make(map[string]bool)}
+
+	for _, origin := range cfg.AllowOrigins {
+		origin = strings.ToLower(strings.TrimSpace(origin))
+		switch {
+		case origin == "*":
+			p.any = true
+		case strings.Contains(origin, "*"):
+			w, err := parseCORSWildcard(origin)
+			if err != nil {
+				return nil, err
+			}
+			p.wildcards = append(p.wildcards, w)
+		default:
+			if u, err := url.Parse(origin); err != nil || u.Scheme == "" || u.Host == "" || u.Path != "" {
+				return nil, fmt.Errorf("cors: origin %q should be like https://a.com", origin)
+			}
+			p.exact[origin] = true
+		}
+	}
+	for _, expr := range cfg.AllowOriginRegex {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("cors: origin regex %q: %w", expr, err)
+		}
+		p.regexps = append(p.regexps, re)
+	}
+
+	for _, method := range cfg.AllowMethods {
+		if method == "*" {
+			return nil, errors.New("cors: list the allowed methods instead of *")
+		}
+		p.methods[strings.ToUpper(method)] = true
+	}
+	for _, header := range cfg.AllowHeaders {
+		if header == "*" {
+			p.headers = nil
+			break
+		}
+		p.headers[http.CanonicalHeaderKey(header)] = true
+	}
+
+	if cfg.AllowCredentials {
+		switch {
+		case p.any:
+			return nil, errors.New("cors: origin * can not be used with credentials")
+		case p.headers == nil:
+			return nil, errors.New("cors: allowed header * can not be used with credentials")
+		}
+		for _, header := range cfg.ExposeHeaders {
+			if header == "*" {
+				return nil, errors.New("cors: exposed header * can not be used with credentials")
+			}
+		}
+	}
+
+	methods := make([]string, 0, len(p.methods))
+	for method := range p.methods {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	p.allow[0] = strings.Join(methods, ", ")
+	p.allow[1] = strings.Join(cfg.AllowHeaders, ", ")
+	p.allow[2] = strings.Join(cfg.ExposeHeaders, ", ")
+	if cfg.MaxAge > 0 {
+		p.maxAge = strconv.Itoa(int(cfg.MaxAge.Seconds()))
+	}
+	return p, nil
+}
+
+// parseCORSWildcard parse https://*.a.com
+func parseCORSWildcard(origin string) (corsWildcard, error) {
+	i := strings.Index(origin, "://*.")
+	if i <= 0 || strings.Count(origin, "*") != 1 || strings.Contains(origin[i+5:], "/") || len(origin) == i+5 {
+		return corsWildcard{}, fmt.Errorf("cors: wildcard origin %q should be like https://*.a.com", origin)
+	}
+	return corsWildcard{scheme: origin[:i+3], suffix: origin[i+4:]}, nil
+}
+
+// allowOrigin report whether the origin is allowed
+func (p *corsPolicy) allowOrigin(origin string) bool {
+	if p.any {
+		return true
+	}
+	lower := strings.ToLower(origin)
+	if p.exact[lower] {
+		return true
+	}
+	for _, w := range p.wildcards {
+		if strings.HasPrefix(lower, w.scheme) && strings.HasSuffix(lower, w.suffix) && len(lower) > len(w.scheme)+len(w.suffix) {
+			return true
+		}
+	}
+	for _, re := range p.regexps {
+		if re.MatchString(origin) {
+			return true
+		}
+	}
+	return p.cfg.AllowOriginFunc != nil && p.cfg.AllowOriginFunc(origin)
+}
+
+// allowHeaders report whether all the requested headers are allowed
+func (p *corsPolicy) allowHeaders(requested string) bool {
+	if p.headers == nil {
+		return true
+	}
+	for _, header := range strings.Split(requested, ",") {
+		header = strings.TrimSpace(header)
+		if header != "" && !p.headers[http.CanonicalHeaderKey(header)] {
+			return false
+		}
+	}
+	return true
+}
+
+// handle answer preflight requests and set the headers of actual requests
+// it returns false when the request was answered
+func (p *corsPolicy) handle(c *gin.Context) bool {
+	origin := c.GetHeader("Origin")
+	if origin == "" || sameOrigin(c.Request, origin) {
+		return true
+	}
+	h := c.Writer.Header()
+	h.Add("Vary", "Origin")
+	preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+	if preflight {
+		h.Add("Vary", "Access-Control-Request-Method")
+		h.Add("Vary", "Access-Control-Request-Headers")
+	}
+
+	if !p.allowOrigin(origin) ||
+		preflight && (!p.methods[strings.ToUpper(c.GetHeader("Access-Control-Request-Method"))] ||
+			!p.allowHeaders(c.GetHeader("Access-Control-Request-Headers"))) {
+		if p.cfg.OnReject != nil {
+			p.cfg.OnReject(c)
+		}
+		if !preflight {
+			// the response goes without the cors headers, the browser does not expose it
+			return true
+		}
+		Fail(c, ErrForbidden.WithMessage("cors request rejected"))
+		return false
+	}
+
+	if p.any && !p.cfg.AllowCredentials {
+		h.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		h.Set("Access-Control-Allow-Origin", origin)
+	}
+	if p.cfg.AllowCredentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+	if !preflight {
+		if p.allow[2] != "" {
+			h.Set("Access-Control-Expose-Headers", p.allow[2])
+		}
+		return true
+	}
+
+	h.Set("Access-Control-Allow-Methods", p.allow[0])
+	if p.headers == nil {
+		// echo the requested headers, * is not understood by every browser
+		if requested := c.GetHeader("Access-Control-Request-Headers"); requested != "" {
+			h.Set("Access-Control-Allow-Headers", requested)
+		}
+	} else {
+		h.Set("Access-Control-Allow-Headers", p.allow[1])
+	}
+	if p.maxAge != "" {
+		h.Set("Access-Control-Max-Age", p.maxAge)
+	}
+	c.AbortWithStatus(http.StatusNoContent)
+	return false
+}
+
+// sameOrigin report whether the origin is the scheme and host of the request
+func sameOrigin(r *http.Request, origin string) bool {
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	scheme := "http"
+	if isHTTPS(r) {
+		scheme = "https"
+	}
+	return strings.EqualFold(u.Scheme, scheme) && strings.EqualFold(originHost(u.Host, scheme), originHost(r.Host, scheme))
+}
+
+// originHost host without the default port of the scheme
+func originHost(host, scheme string) string {
+	if scheme == "https" {
+		return strings.TrimSuffix(host, ":443")
+	}
+	return strings.TrimSuffix(host, ":80")
+}
+
+// CORS cross-origin middleware, unsafe configs such as * with credentials are rejected
+// preflight requests are answered with 204 and disallowed origins, methods or headers with 403;
+// the other requests of disallowed origins, null included, are served without the cors headers,
+// so the browser does not expose the response to the page
+//
+// gin only runs the engine middleware for the preflight of a route without OPTIONS handler,
+// so use it with Use on the engine, or CORSGroups for policies per route group
+func CORS(cfg CORSConfig) (gin.HandlerFunc, error) {
+	p, err := newCORSPolicy(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return func(c *gin.Context) {
+		if p.handle(c) {
+			c.Next()
+		}
+	}, nil
+}
+
+// CORSGroup CORS policy of the routes under the path prefix
+type CORSGroup struct {
+	Prefix string     // path prefix, demo: /api/admin
+	Config CORSConfig // policy
+}
+
+// CORSGroups engine middleware applying the policy of the longest matching prefix
+// requests matching no prefix are not CORS enabled
+func CORSGroups(groups ...CORSGroup) (gin.HandlerFunc, error) {
+	type prefixPolicy struct {
+		prefix string
+		policy *corsPolicy
+	}
+	policies := make([]prefixPolicy, 0, len(groups))
+	for _, g := range groups {
+		p, err := newCORSPolicy(g.Config)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", g.Prefix, err)
+		}
+		policies = append(policies, prefixPolicy{prefix: "/" + strings.Trim(g.Prefix, "/"), policy: p})
+	}
+	sort.SliceStable(policies, func(i, j int) bool {
+		return len(policies[i].prefix) > len(policies[j].prefix)
+	})
+
+	return func(c *gin.Context) {
+		path := c.Request.URL.Path
+		for _, pp := range policies {
+			if pp.prefix == "/" || path == pp.prefix || strings.HasPrefix(path, pp.prefix+"/") {
+				if pp.policy.handle(c) {
+					c.Next()
+				}
+				return
+			}
+		}
+		c.Next()
+	}, nil
+}
@@ -0,0 +1,107 @@
+package ginx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCORS(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cors, err := CORS(CORSConfig{
+		AllowOrigins:     []string{"https://app.example.com", "https://*.example.org"},
+		AllowHeaders:     []string{"Content-Type", "Authorization"},
+		ExposeHeaders:    []string{RequestIDHeader},
+		AllowCredentials: true,
+		MaxAge:           time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := gin.New()
+	e.Use(cors)
+	e.GET("/items", func(c *gin.Context) { c.String(http.StatusOK, "items") })
+	e.POST("/items", func(c *gin.Context) { c.String(http.StatusCreated, "created") })
+
+	tests := []struct {
+		name      string
+		method    string
+		origin    string
+		reqMethod string // Access-Control-Request-Method of a preflight
+		reqHeader string // Access-Control-Request-Headers of a preflight
+		https     bool   // request received over https
+		status    int
+		allowed   string // Access-Control-Allow-Origin
+	}{
+		{"no origin", http.MethodGet, "", "", "", false, http.StatusOK, ""},
+		{"simple allowed", http.MethodGet, "https://app.example.com", "", "", false, http.StatusOK, "https://app.example.com"},
+		{"simple wildcard subdomain", http.MethodGet, "https://a.b.example.org", "", "", false, http.StatusOK, "https://a.b.example.org"},
+		{"simple denied", http.MethodGet, "https://evil.com", "", "", false, http.StatusOK, ""},
+		{"wildcard needs the scheme", http.MethodGet, "http://a.example.org", "", "", false, http.StatusOK, ""},
+		{"wildcard needs a subdomain", http.MethodGet, "https://example.org", "", "", false, http.StatusOK, ""},
+		{"preflight allowed", http.MethodOptions, "https://app.example.com", http.MethodPost, "content-type", false, http.StatusNoContent, "https://app.example.com"},
+		{"preflight method denied", http.MethodOptions, "https://app.example.com", "CONNECT", "", false, http.StatusForbidden, ""},
+		{"preflight header denied", http.MethodOptions, "https://app.example.com", http.MethodPost, "X-Secret", false, http.StatusForbidden, ""},
+		{"preflight origin denied", http.MethodOptions, "https://evil.com", http.MethodPost, "", false, http.StatusForbidden, ""},
+		{"null origin", http.MethodGet, "null", "", "", false, http.StatusOK, ""},
+		{"null origin post", http.MethodPost, "null", "", "", false, http.StatusCreated, ""},
+		{"null origin preflight", http.MethodOptions, "null", http.MethodPost, "", false, http.StatusForbidden, ""},
+		{"simple post denied", http.MethodPost, "https://evil.com", "", "", false, http.StatusCreated, ""},
+		{"same origin", http.MethodPost, "https://api.local", "", "", true, http.StatusCreated, ""},
+		{"same host other scheme", http.MethodPost, "http://api.local", "", "", true, http.StatusCreated, ""},
+		{"same origin default port", http.MethodPost, "https://api.local:443", "", "", true, http.StatusCreated, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "http://api.local/items", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.reqMethod != "" {
+				req.Header.Set("Access-Control-Request-Method", tt.reqMethod)
+			}
+			if tt.reqHeader != "" {
+				req.Header.Set("Access-Control-Request-Headers", tt.reqHeader)
+			}
+			if tt.https {
+				// through a trusted proxy
+				req.RemoteAddr = "127.0.0.1:1234"
+				req.Header.Set("X-Forwarded-Proto", "https")
+			}
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, req)
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+			h := w.Header()
+			if got := h.Get("Access-Control-Allow-Origin"); got != tt.allowed {
+				t.Fatalf("Allow-Origin %q, want %q", got, tt.allowed)
+			}
+			if tt.allowed == "" {
+				for k := range h {
+					if strings.HasPrefix(k, "Access-Control-") {
+						t.Fatalf("cors header %s set for a disallowed request", k)
+					}
+				}
+				return
+			}
+			if h.Get("Access-Control-Allow-Credentials") != "true" {
+				t.Fatal("Allow-Credentials not set")
+			}
+			if tt.reqMethod != "" && (h.Get("Access-Control-Allow-Methods") == "" || h.Get("Access-Control-Max-Age") != "3600") {
+				t.Fatalf("preflight headers %v", h)
+			}
+			if tt.reqMethod == "" && h.Get("Access-Control-Expose-Headers") != RequestIDHeader {
+				t.Fatalf("Expose-Headers %q", h.Get("Access-Control-Expose-Headers"))
+			}
+		})
+	}
+
+	if _, err := CORS(CORSConfig{AllowOrigins: []string{"*"}, AllowCredentials: true}); err == nil {
+		t.Fatal("* with credentials accepted")
+	}
+}
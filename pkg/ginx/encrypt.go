@@ -0,0 +1,296 @@
+package ginx
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/miajio/gin-screw/pkg/stringutil"
+)
+
+// EncryptedHeader set on encrypted responses
+const EncryptedHeader = "X-Encrypted"
+
+// ClientIDHeader client id header used by ClientKeys
+const ClientIDHeader = "X-Client-Id"
+
+// ErrDecrypt the request body can not be decrypted
+var ErrDecrypt = NewBizError(40001, http.StatusBadRequest, "decrypt_failed", "request body can not be decrypted")
+
+// KeyResolver AES key of the client of the request, 16, 24 or 32 bytes
+type KeyResolver func(c *gin.Context) (string, error)
+
+// ClientKeys resolve the key by the X-Client-Id header, or by the app key of a SignAuth request
+func ClientKeys(keys map[string]string) KeyResolver {
+	return func(c *gin.Context) (string, error) {
+		id := c.GetHeader(ClientIDHeader)
+		if id == "" {
+			id = AppKey(c)
+		}
+		if key, ok := keys[id]; ok && id != "" {
+			return key, nil
+		}
+		return "", ErrUnauthorized.WithMessage("unknown client")
+	}
+}
+
+// Cipher body cipher of the Encrypt middleware, sent in the X-Encrypted response header
+type Cipher string
+
+const (
+	CipherCBC Cipher = "aes-cbc" // stringutil.AesEncrypt and AesDecrypt, what the existing clients send
+	CipherGCM Cipher = "aes-gcm" // stringutil.AesGcmEncrypt and AesGcmDecrypt, authenticated
+)
+
+// encrypt encrypt the value with the cipher
+func (ci Cipher) encrypt(v, k string) (string, error) {
+	if ci == CipherGCM {
+		return stringutil.AesGcmEncrypt(v, k)
+	}
+	return stringutil.AesEncrypt(v, k)
+}
+
+// decrypt decrypt the value with the cipher
+func (ci Cipher) decrypt(v, k string) (string, error) {
+	if ci == CipherGCM {
+		return stringutil.AesGcmDecrypt(v, k)
+	}
+	return stringutil.AesDecrypt(v, k)
+}
+
+// EncryptConfig encrypted body middleware config
+type EncryptConfig struct {
+	Keys    KeyResolver // required
+	Cipher  Cipher      // default CipherCBC, CipherGCM for the clients that support it
+	OptIn   bool        // only routes marked with Encrypted are encrypted, default all but Unencrypted routes
+	MaxBody int64       // max encrypted request body bytes, default 1MB
+}
+
+const encryptKey = "ginx.encrypt"
+
+// Encrypted mark the route as encrypted
+func Encrypted() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(encryptKey, true)
+	}
+}
+
+// Unencrypted mark the route as plain
+func Unencrypted() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(encryptKey, false)
+	}
+}
+
+// Encrypt decrypt request bodies and encrypt response bodies with the configured cipher
+// the bodies are the base64 of the AES-CBC payload of stringutil.AesEncrypt by default, or of the nonce
+// and the AES-GCM sealed payload with CipherGCM, so Handle and the gin binding see the plain json;
+// a body that can not be decrypted fails with ErrDecrypt
+// CBC bodies are not authenticated, prefer CipherGCM when the clients support it
+//
+// the request body is decrypted when it is read and the response body is buffered,
+// so the Encrypted and Unencrypted markers work on the routes registered after the middleware;
+// streaming routes should be Unencrypted
+func Encrypt(cfg EncryptConfig) gin.HandlerFunc {
+	if cfg.Keys == nil {
+		panic("encrypt needs a key resolver")
+	}
+	if cfg.MaxBody <= 0 {
+		cfg.MaxBody = 1 << 20
+	}
+	switch cfg.Cipher {
+	case "":
+		cfg.Cipher = CipherCBC
+	case CipherCBC, CipherGCM:
+	default:
+		panic("encrypt cipher " + string(cfg.Cipher) + " is not supported")
+	}
+	return func(c *gin.Context) {
+		s := &cryptoState{c: c, cfg: cfg}
+		if c.Request.Body != nil && c.Request.Body != http.NoBody {
+			c.Request.Body = &decryptReader{s: s, raw: c.Request.Body}
+			c.Request.ContentLength = -1
+		}
+		w := &encryptWriter{ResponseWriter: c.Writer, s: s, status: http.StatusOK}
+		c.Writer = w
+		defer func() {
+			// on panic the recovery middleware writes its plain error to the client
+			if c.Writer == gin.ResponseWriter(w) {
+				c.Writer = w.ResponseWriter
+			}
+		}()
+
+		c.Next()
+
+		c.Writer = w.ResponseWriter
+		if !w.buffered {
+			return
+		}
+		if w.buf.Len() == 0 {
+			c.Writer.WriteHeaderNow()
+			return
+		}
+		key, err := s.key()
+		if err != nil {
+			Fail(c, err)
+			return
+		}
+		data, err := cfg.Cipher.encrypt(w.buf.String(), key)
+		if err != nil {
+			Fail(c, ErrInternal.Wrap(err))
+			return
+		}
+		h := c.Writer.Header()
+		h.Del("Content-Length")
+		h.Set("Content-Type", "text/plain; charset=utf-8")
+		h.Set(EncryptedHeader, string(cfg.Cipher))
+		c.Writer.WriteHeader(w.status)
+		_, _ = c.Writer.WriteString(data)
+	}
+}
+
+// cryptoState encryption state of one request
+type cryptoState struct {
+	c        *gin.Context
+	cfg      EncryptConfig
+	resolved bool
+	k        string
+	err      error
+}
+
+// enabled report whether the route is encrypted
+func (s *cryptoState) enabled() bool {
+	if v, ok := s.c.Get(encryptKey); ok {
+		return v.(bool)
+	}
+	return !s.cfg.OptIn
+}
+
+// key resolve the key once
+func (s *cryptoState) key() (string, error) {
+	if !s.resolved {
+		s.k, s.err = s.cfg.Keys(s.c)
+		s.resolved = true
+	}
+	return s.k, s.err
+}
+
+// decryptReader decrypt the request body on the first read
+type decryptReader struct {
+	s   *cryptoState
+	raw io.ReadCloser
+	r   io.Reader
+}
+
+// Read read the plain body
+func (d *decryptReader) Read(p []byte) (int, error) {
+	if d.r == nil {
+		r, err := d.open()
+		if err != nil {
+			return 0, err
+		}
+		d.r = r
+	}
+	return d.r.Read(p)
+}
+
+// open decide and decrypt
+func (d *decryptReader) open() (io.Reader, error) {
+	if !d.s.enabled() {
+		return d.raw, nil
+	}
+	data, err := io.ReadAll(io.LimitReader(d.raw, d.s.cfg.MaxBody+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > d.s.cfg.MaxBody {
+		return nil, ErrPayloadTooLarge
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return bytes.NewReader(nil), nil
+	}
+	key, err := d.s.key()
+	if err != nil {
+		return nil, err
+	}
+	plain, err := d.s.cfg.Cipher.decrypt(strings.TrimSpace(string(data)), key)
+	if err != nil {
+		return nil, ErrDecrypt.Wrap(err)
+	}
+	return strings.NewReader(plain), nil
+}
+
+// Close close the raw body
+func (d *decryptReader) Close() error {
+	return d.raw.Close()
+}
+
+// encryptWriter buffer the response body of encrypted routes
+type encryptWriter struct {
+	gin.ResponseWriter
+	s        *cryptoState
+	decided  bool
+	buffered bool
+	status   int
+	buf      bytes.Buffer
+}
+
+// Unwrap the wrapped writer, for http.ResponseController
+func (w *encryptWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// decide buffer or pass through on the first write
+func (w *encryptWriter) decide() {
+	if !w.decided {
+		w.decided = true
+		w.buffered = w.s.enabled()
+	}
+}
+
+// WriteHeader keep the status of a buffered response
+func (w *encryptWriter) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write buffer or pass through
+func (w *encryptWriter) Write(p []byte) (int, error) {
+	w.decide()
+	if w.buffered {
+		return w.buf.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// WriteString buffer or pass through
+func (w *encryptWriter) WriteString(s string) (int, error) {
+	w.decide()
+	if w.buffered {
+		return w.buf.WriteString(s)
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Written report whether the body was written
+func (w *encryptWriter) Written() bool {
+	return w.buf.Len() > 0 || w.ResponseWriter.Written()
+}
+
+// WriteHeaderNow pass through, the header of a buffered response is written at the end
+func (w *encryptWriter) WriteHeaderNow() {
+	w.decide()
+	if !w.buffered {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+// Flush flush the plain responses only
+func (w *encryptWriter) Flush() {
+	w.decide()
+	if !w.buffered {
+		w.ResponseWriter.Flush()
+	}
+}
@@ -0,0 +1,128 @@
+package ginx
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/miajio/gin-screw/pkg/stringutil"
+)
+
+const testAesKey = "0123456789abcdef"
+
+func newEncryptEngine(cipher Cipher) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(Recovery(RecoveryConfig{}), Encrypt(EncryptConfig{Keys: ClientKeys(map[string]string{"ios": testAesKey}), Cipher: cipher}))
+	e.POST("/echo", func(c *gin.Context) {
+		var body map[string]any
+		if err := c.ShouldBindJSON(&body); err != nil {
+			Fail(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, body)
+	})
+	e.GET("/plain", Unencrypted(), func(c *gin.Context) {
+		c.String(http.StatusOK, "plain")
+	})
+	e.GET("/panic", func(c *gin.Context) {
+		c.String(http.StatusOK, "partial")
+		panic("boom")
+	})
+	return e
+}
+
+func TestEncrypt(t *testing.T) {
+	const payload = `{"name":"ginx"}`
+	cbc, err := stringutil.AesEncrypt(payload, testAesKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := stringutil.AesGcmEncrypt(payload, testAesKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := []byte(sealed)
+	tampered[len(tampered)/2] ^= 1
+
+	tests := []struct {
+		name      string
+		cipher    Cipher
+		method    string
+		path      string
+		client    string
+		body      string
+		status    int
+		encrypted bool
+		plain     string // expected plain response, decrypted when encrypted
+	}{
+		{"cbc by default", "", http.MethodPost, "/echo", "ios", cbc, http.StatusOK, true, payload},
+		{"cbc garbage", "", http.MethodPost, "/echo", "ios", "not base64", http.StatusBadRequest, true, ""},
+		{"gcm round trip", CipherGCM, http.MethodPost, "/echo", "ios", sealed, http.StatusOK, true, payload},
+		{"gcm tampered body", CipherGCM, http.MethodPost, "/echo", "ios", string(tampered), http.StatusBadRequest, true, ""},
+		{"cbc body on gcm", CipherGCM, http.MethodPost, "/echo", "ios", cbc, http.StatusBadRequest, true, ""},
+		{"unknown client", "", http.MethodPost, "/echo", "android", cbc, http.StatusUnauthorized, false, ""},
+		{"unencrypted route", "", http.MethodGet, "/plain", "", "", http.StatusOK, false, "plain"},
+		{"panic is a plain error", "", http.MethodGet, "/panic", "ios", "", http.StatusInternalServerError, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newEncryptEngine(tt.cipher)
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.client != "" {
+				req.Header.Set(ClientIDHeader, tt.client)
+			}
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, req)
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+			cipher := Cipher(w.Header().Get(EncryptedHeader))
+			if got := cipher != ""; got != tt.encrypted {
+				t.Fatalf("encrypted %v, want %v", got, tt.encrypted)
+			}
+			body := w.Body.String()
+			if tt.encrypted {
+				if want := tt.cipher; cipher != want && !(want == "" && cipher == CipherCBC) {
+					t.Fatalf("cipher %q, want %q", cipher, want)
+				}
+				var err error
+				if body, err = cipher.decrypt(body, testAesKey); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.plain != "" && body != tt.plain {
+				t.Fatalf("body %q, want %q", body, tt.plain)
+			}
+			if tt.status >= http.StatusBadRequest {
+				var resp Response
+				if err := json.Unmarshal([]byte(body), &resp); err != nil || resp.Code == 0 {
+					t.Fatalf("error body %q is not the Fail envelope", body)
+				}
+			}
+		})
+	}
+}
+
+func TestAesDecryptPadding(t *testing.T) {
+	sealed, _ := stringutil.AesEncrypt("hello", testAesKey)
+	if plain, err := stringutil.AesDecrypt(sealed, testAesKey); err != nil || plain != "hello" {
+		t.Fatalf("AesDecrypt = %q, %v", plain, err)
+	}
+	// a block that decrypts to a bad pad byte
+	block, _ := stringutil.AesEncrypt(strings.Repeat("x", 16), testAesKey)
+	raw, _ := base64.StdEncoding.DecodeString(block)
+	badPad := base64.StdEncoding.EncodeToString(raw[:16])
+	// every bad ciphertext fails with the same error, whatever is wrong with it
+	for _, v := range []string{"", "not base64", "Zm9v", strings.Repeat("A", 24), strings.Repeat("A", 44), badPad} {
+		if _, err := stringutil.AesDecrypt(v, testAesKey); !errors.Is(err, stringutil.ErrAesDecrypt) {
+			t.Fatalf("AesDecrypt(%q) = %v, want ErrAesDecrypt", v, err)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package ginx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/miajio/gin-screw/pkg/validate"
+)
+
+// StatusClientClosedRequest the client closed the request before the handler finished
+const StatusClientClosedRequest = 499
+
+// StatusCoder error carrying its http status code
+type StatusCoder interface {
+	StatusCode() int
+}
+
+// BindError request binding error
+type BindError struct {
+	Source string // body, query, uri or header
+	Err    error
+}
+
+// Error implement error
+func (e *BindError) Error() string {
+	return fmt.Sprintf("bind %s: %v", e.Source, e.Err)
+}
+
+// Unwrap the binding error
+func (e *BindError) Unwrap() error {
+	return e.Err
+}
+
+// StatusCode bad request, or payload too large when the body exceeds BodyLimit
+func (e *BindError) StatusCode() int {
+	var mbe *http.MaxBytesError
+	if errors.As(e.Err, &mbe) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
+type ginContextKey struct{}
+
+var validatorsOnce sync.Once // register the pkg/validate validators once
+
+// GinContext get the gin context from the context passed to a typed handler
+func GinContext(ctx context.Context) (*gin.Context, bool) {
+	c, ok := ctx.Value(ginContextKey{}).(*gin.Context)
+	return c, ok
+}
+
+// Handle build a gin handler from a typed handler
+// the request is bound from the json or form body, the query (form tags),
+// the uri (uri tags) and the headers (header tags), then checked by the binding tags
+// including the validators put in pkg/validate
+// the response is rendered by OK and the errors by Fail
+// the pkg/validate validators are registered on the first Handle, the ones put later are registered by validate.Put
+func Handle[Req, Resp any](fn func(ctx context.Context, req *Req) (*Resp, error)) gin.HandlerFunc {
+	validatorsOnce.Do(validate.Execute)
+	headers := headerTags(reflect.TypeOf((*Req)(nil)).Elem())
+	h := func(c *gin.Context) {
+		req := new(Req)
+		if err := bindRequest(c, req, headers); err != nil {
+			Fail(c, err)
+			return
+		}
+		ctx := context.WithValue(c.Request.Context(), ginContextKey{}, c)
+		resp, err := fn(ctx, req)
+		if err != nil {
+			Fail(c, err)
+			return
+		}
+		OK(c, resp)
+	}
+	return h
+}
+
+// bindRequest bind every source then validate once
+func bindRequest(c *gin.Context, req any, headers []string) error {
+	if err := bindBody(c.Request, req); err != nil {
+		return &BindError{Source: "body", Err: err}
+	}
+	if reflect.Indirect(reflect.ValueOf(req)).Kind() != reflect.Struct {
+		return validateRequest(req)
+	}
+	if err := binding.MapFormWithTag(req, c.Request.URL.Query(), "form"); err != nil {
+		return &BindError{Source: "query", Err: err}
+	}
+	if len(c.Params) > 0 {
+		params := make(map[string][]string, len(c.Params))
+		for _, p := range c.Params {
+			params[p.Key] = []string{p.Value}
+		}
+		if err := binding.MapFormWithTag(req, params, "uri"); err != nil {
+			return &BindError{Source: "uri", Err: err}
+		}
+	}
+	if len(headers) > 0 {
+		values := make(map[string][]string, len(headers))
+		for _, name := range headers {
+			if v := c.Request.Header.Values(name); len(v) > 0 {
+				values[name] = v
+			}
+		}
+		if err := binding.MapFormWithTag(req, values, "header"); err != nil {
+			return &BindError{Source: "header", Err: err}
+		}
+	}
+	return validateRequest(req)
+}
+
+// bindBody decode the json or form body
+func bindBody(r *http.Request, req any) error {
+	if r.Body == nil || r.Body == http.NoBody || r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return nil
+	}
+	switch contentType(r) {
+	case binding.MIMEPOSTForm, binding.MIMEMultipartPOSTForm:
+		if err := r.ParseMultipartForm(32 << 20); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+			return err
+		}
+		return binding.MapFormWithTag(req, r.PostForm, "form")
+	default:
+		err := json.NewDecoder(r.Body).Decode(req)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		return err
+	}
+}
+
+// validateRequest run the binding validator
+func validateRequest(req any) error {
+	if binding.Validator == nil {
+		return nil
+	}
+	return binding.Validator.ValidateStruct(req)
+}
+
+// contentType media type of the request without parameters
+func contentType(r *http.Request) string {
+	ct, _, _ := strings.Cut(r.Header.Get("Content-Type"), ";")
+	return strings.TrimSpace(strings.ToLower(ct))
+}
+
+// headerTags header names wanted by the request struct
+func headerTags(t reflect.Type) []string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	result := make([]string, 0)
+	eachField(t, func(field reflect.StructField) {
+		if name := tagName(field, "header"); name != "" {
+			result = append(result, name)
+		}
+	})
+	return result
+}
+
+// StatusOf map the error to http status: StatusCoder, 400 for bind and validation errors,
+// 504 for deadline exceeded, 499 for canceled and 500 for the others
+func StatusOf(err error) int {
+	var sc StatusCoder
+	var ve validator.ValidationErrors
+	var mbe *http.MaxBytesError
+	switch {
+	case err == nil:
+		return http.StatusOK
+	case errors.As(err, &sc):
+		return sc.StatusCode()
+	case errors.As(err, &mbe):
+		return http.StatusRequestEntityTooLarge
+	case errors.As(err, &ve):
+		return http.StatusBadRequest
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, context.Canceled):
+		return StatusClientClosedRequest
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,49 @@
+package ginx
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/miajio/gin-screw/pkg/validate"
+)
+
+type lateValidatorReq struct {
+	Code string `json:"code" binding:"required,lateupper"`
+}
+
+func TestHandleValidatorPutLater(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.POST("/codes", Handle(func(ctx context.Context, req *lateValidatorReq) (*lateValidatorReq, error) {
+		return req, nil
+	}))
+	// put after the first Handle registered the validators
+	validate.Put("lateupper", func(fl validator.FieldLevel) bool {
+		return fl.Field().String() == strings.ToUpper(fl.Field().String())
+	})
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"valid", `{"code":"ABC"}`, http.StatusOK},
+		{"invalid", `{"code":"abc"}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/codes", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, req)
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+		})
+	}
+}
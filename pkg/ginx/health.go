@@ -0,0 +1,265 @@
+package ginx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	filieutil "github.com/miajio/gin-screw/pkg/filie_util"
+)
+
+// Checker health checker
+type Checker interface {
+	Name() string                    // check name in the report
+	Check(ctx context.Context) error // nil is healthy
+}
+
+// checkerFunc Checker from a func
+type checkerFunc struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// Name check name
+func (c checkerFunc) Name() string {
+	return c.name
+}
+
+// Check run the func
+func (c checkerFunc) Check(ctx context.Context) error {
+	return c.fn(ctx)
+}
+
+// NewChecker create a checker from a func
+func NewChecker(name string, fn func(ctx context.Context) error) Checker {
+	return checkerFunc{name: name, fn: fn}
+}
+
+// Pinger demo: *sql.DB
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// PingChecker check a database or anything that can be pinged
+func PingChecker(name string, p Pinger) Checker {
+	return NewChecker(name, p.PingContext)
+}
+
+// HTTPChecker check a downstream http service answers GET url with a status below 400
+func HTTPChecker(name, url string, client *http.Client) Checker {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return NewChecker(name, func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= http.StatusBadRequest {
+			return fmt.Errorf("%s answered %d", url, resp.StatusCode)
+		}
+		return nil
+	})
+}
+
+// DiskChecker check the folder exists and its file system has minFree bytes free at least
+func DiskChecker(name, path string, minFree uint64) Checker {
+	return NewChecker(name, func(ctx context.Context) error {
+		f, err := filieutil.New(path)
+		if err != nil {
+			return err
+		}
+		if !f.IsDir() {
+			return fmt.Errorf("%s path not a folder", path)
+		}
+		free, err := filieutil.FreeSpace(f.GetPath())
+		if err != nil {
+			return err
+		}
+		if free < minFree {
+			return fmt.Errorf("%s has %d bytes free, need %d", path, free, minFree)
+		}
+		return nil
+	})
+}
+
+// HealthConfig health endpoints config
+type HealthConfig struct {
+	Timeout  time.Duration // timeout of one check, default 2s
+	CacheTTL time.Duration // results are reused for this long, 0 disable
+}
+
+// HealthReport aggregated health report
+type HealthReport struct {
+	Status string                  `json:"status"` // ok or fail
+	Checks map[string]*CheckResult `json:"checks,omitempty"`
+}
+
+// CheckResult result of one check
+type CheckResult struct {
+	Status  string    `json:"status"` // ok or fail
+	Error   string    `json:"error,omitempty"`
+	Latency string    `json:"latency"`
+	At      time.Time `json:"at"`
+}
+
+// Health liveness and readiness checks
+type Health struct {
+	cfg       HealthConfig
+	app       *App
+	mu        sync.Mutex
+	liveness  []Checker
+	readiness []Checker
+	cache     map[string]*CheckResult
+}
+
+// EnableHealth register /healthz, /livez and /readyz
+// readiness fails as soon as the graceful shutdown of Run starts
+func (a *App) EnableHealth(cfg HealthConfig) *Health {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 2 * time.Second
+	}
+	h := &Health{cfg: cfg, app: a, cache: make(map[string]*CheckResult)}
+	e := a.Engine()
+	e.GET("/healthz", h.handler(true, true))
+	e.GET("/livez", h.handler(true, false))
+	e.GET("/readyz", h.handler(false, true))
+	return h
+}
+
+// EnableHealth register the health endpoints of the singleton app
+func EnableHealth(cfg HealthConfig) *Health {
+	check()
+	return this.EnableHealth(cfg)
+}
+
+// AddLiveness add checkers telling whether the process must be restarted
+// it panics on a name already used by a liveness or readiness checker
+func (h *Health) AddLiveness(checkers ...Checker) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checkNames(checkers)
+	h.liveness = append(h.liveness, checkers...)
+}
+
+// AddReadiness add checkers telling whether the process can take traffic
+// it panics on a name already used by a liveness or readiness checker
+func (h *Health) AddReadiness(checkers ...Checker) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checkNames(checkers)
+	h.readiness = append(h.readiness, checkers...)
+}
+
+// checkNames panic on a duplicate checker name, as the report and the cache are keyed by name, h.mu is held
+func (h *Health) checkNames(checkers []Checker) {
+	names := map[string]bool{"shutdown": true}
+	for _, c := range h.liveness {
+		names[c.Name()] = true
+	}
+	for _, c := range h.readiness {
+		names[c.Name()] = true
+	}
+	for _, c := range checkers {
+		if names[c.Name()] {
+			panic(fmt.Sprintf("health checker name %q already used", c.Name()))
+		}
+		names[c.Name()] = true
+	}
+}
+
+// Report run the checks
+func (h *Health) Report(ctx context.Context, liveness, readiness bool) *HealthReport {
+	h.mu.Lock()
+	checkers := make([]Checker, 0)
+	if liveness {
+		checkers = append(checkers, h.liveness...)
+	}
+	if readiness {
+		checkers = append(checkers, h.readiness...)
+	}
+	h.mu.Unlock()
+
+	report := &HealthReport{Status: "ok", Checks: make(map[string]*CheckResult, len(checkers))}
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	for _, checker := range checkers {
+		wg.Add(1)
+		go func(checker Checker) {
+			defer wg.Done()
+			r := h.run(ctx, checker)
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[checker.Name()] = r
+			if r.Status != "ok" {
+				report.Status = "fail"
+			}
+		}(checker)
+	}
+	wg.Wait()
+
+	if readiness && h.app.ShuttingDown() {
+		report.Status = "fail"
+		report.Checks["shutdown"] = &CheckResult{Status: "fail", Error: "server is shutting down", Latency: "0s", At: time.Now()}
+	}
+	return report
+}
+
+// run run one checker or reuse its cached result
+func (h *Health) run(ctx context.Context, checker Checker) *CheckResult {
+	name := checker.Name()
+	if h.cfg.CacheTTL > 0 {
+		h.mu.Lock()
+		r, ok := h.cache[name]
+		h.mu.Unlock()
+		if ok && time.Since(r.At) < h.cfg.CacheTTL {
+			return r
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, h.cfg.Timeout)
+	defer cancel()
+	start := time.Now()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- checker.Check(ctx)
+	}()
+	var err error
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+		err = fmt.Errorf("check timeout after %s", h.cfg.Timeout)
+	}
+
+	r := &CheckResult{Status: "ok", Latency: time.Since(start).String(), At: time.Now()}
+	if err != nil {
+		r.Status, r.Error = "fail", err.Error()
+	}
+	if h.cfg.CacheTTL > 0 && !errors.Is(ctx.Err(), context.Canceled) {
+		h.mu.Lock()
+		h.cache[name] = r
+		h.mu.Unlock()
+	}
+	return r
+}
+
+// handler render the report, 503 when a check fails
+func (h *Health) handler(liveness, readiness bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		report := h.Report(c.Request.Context(), liveness, readiness)
+		status := http.StatusOK
+		if report.Status != "ok" {
+			status = http.StatusServiceUnavailable
+		}
+		c.JSON(status, report)
+	}
+}
@@ -0,0 +1,148 @@
+package ginx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// getHealth serve the health path and decode the report
+func getHealth(t *testing.T, a *App, path string) (int, *HealthReport) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	a.Engine().ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	report := &HealthReport{}
+	if err := json.Unmarshal(w.Body.Bytes(), report); err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+	return w.Code, report
+}
+
+func TestHealth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	a := New(nil)
+	h := a.EnableHealth(HealthConfig{Timeout: 50 * time.Millisecond})
+	var dbDown atomic.Bool
+	h.AddLiveness(NewChecker("loop", func(context.Context) error { return nil }))
+	h.AddReadiness(
+		NewChecker("db", func(context.Context) error {
+			if dbDown.Load() {
+				return errors.New("db down")
+			}
+			return nil
+		}),
+		NewChecker("slow", func(ctx context.Context) error { <-ctx.Done(); return nil }),
+		DiskChecker("disk", t.TempDir(), 0),
+	)
+
+	code, report := getHealth(t, a, "/livez")
+	if code != http.StatusOK || len(report.Checks) != 1 || report.Checks["loop"].Status != "ok" {
+		t.Fatalf("livez %d %+v", code, report)
+	}
+	code, report = getHealth(t, a, "/readyz")
+	if code != http.StatusServiceUnavailable || report.Checks["db"].Status != "ok" || report.Checks["disk"].Status != "ok" {
+		t.Fatalf("readyz %d %+v", code, report)
+	}
+	if r := report.Checks["slow"]; r.Status != "fail" || r.Error != "check timeout after 50ms" {
+		t.Fatalf("slow check %+v", r)
+	}
+	if _, report = getHealth(t, a, "/healthz"); len(report.Checks) != 4 {
+		t.Fatalf("healthz checks %v", report.Checks)
+	}
+
+	// readiness fails once the shutdown starts, liveness does not
+	a.shuttingDown.Store(true)
+	if code, report = getHealth(t, a, "/readyz"); code != http.StatusServiceUnavailable || report.Checks["shutdown"] == nil {
+		t.Fatalf("readyz while shutting down %d %+v", code, report)
+	}
+	if code, _ = getHealth(t, a, "/livez"); code != http.StatusOK {
+		t.Fatalf("livez while shutting down %d", code)
+	}
+}
+
+func TestHealthCache(t *testing.T) {
+	a := New(nil)
+	h := a.EnableHealth(HealthConfig{CacheTTL: 50 * time.Millisecond})
+	var calls atomic.Int32
+	h.AddReadiness(NewChecker("db", func(context.Context) error {
+		calls.Add(1)
+		return nil
+	}))
+	for i := 0; i < 3; i++ {
+		h.Report(context.Background(), false, true)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("checked %d times within the ttl", n)
+	}
+	time.Sleep(60 * time.Millisecond)
+	h.Report(context.Background(), false, true)
+	if n := calls.Load(); n != 2 {
+		t.Fatalf("checked %d times after the ttl", n)
+	}
+
+	// a check canceled by the caller is not cached
+	time.Sleep(60 * time.Millisecond)
+	cached := h.cache["db"]
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	h.Report(ctx, false, true)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.cache["db"] != cached {
+		t.Fatal("the canceled result was cached")
+	}
+}
+
+func TestHealthDuplicateName(t *testing.T) {
+	tests := []struct {
+		name string
+		add  func(h *Health)
+	}{
+		{"liveness and readiness", func(h *Health) {
+			h.AddLiveness(NewChecker("db", nil))
+			h.AddReadiness(NewChecker("db", nil))
+		}},
+		{"same call", func(h *Health) { h.AddReadiness(NewChecker("db", nil), NewChecker("db", nil)) }},
+		{"reserved shutdown", func(h *Health) { h.AddReadiness(NewChecker("shutdown", nil)) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("duplicate name accepted")
+				}
+			}()
+			tt.add(New(nil).EnableHealth(HealthConfig{}))
+		})
+	}
+}
+
+func TestDiskChecker(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		path    string
+		minFree uint64
+		ok      bool
+	}{
+		{"enough space", dir, 1, true},
+		{"not enough space", dir, math.MaxUint64, false},
+		{"missing folder", dir + "/missing", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := DiskChecker("disk", tt.path, tt.minFree).Check(context.Background())
+			if (err == nil) != tt.ok {
+				t.Fatalf("error %v", err)
+			}
+		})
+	}
+}
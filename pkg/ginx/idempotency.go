@@ -0,0 +1,526 @@
+package ginx
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	filieutil "github.com/miajio/gin-screw/pkg/filie_util"
+	"go.uber.org/zap"
+)
+
+// IdempotencyKeyHeader idempotency key header
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotentReplayedHeader set on replayed responses
+const IdempotentReplayedHeader = "Idempotent-Replayed"
+
+var (
+	ErrIdempotencyInFlight = NewBizError(40901, http.StatusConflict, "idempotency.in_flight", "a request with the same idempotency key is in progress")
+	ErrIdempotencyMismatch = NewBizError(42201, http.StatusUnprocessableEntity, "idempotency.mismatch", "idempotency key reused with a different request body")
+	ErrIdempotencyKey      = NewBizError(40002, http.StatusBadRequest, "idempotency.key_invalid", "idempotency key missing or invalid")
+)
+
+// IdempotencyRecord request known by its idempotency key
+type IdempotencyRecord struct {
+	Fingerprint string      `json:"fingerprint"` // sha256 of the request body
+	Status      int         `json:"status"`      // 0 while the first request is in progress
+	Header      http.Header `json:"header,omitempty"`
+	Body        []byte      `json:"body,omitempty"`
+	Expire      time.Time   `json:"expire"`
+}
+
+// IdempotencyStore idempotency record store
+type IdempotencyStore interface {
+	// Lock create an in-progress record for the key
+	// if the key already has a record, it is returned and locked is false
+	Lock(ctx context.Context, key, fingerprint string, ttl time.Duration) (rec *IdempotencyRecord, locked bool, err error)
+	// Save store the response of the locked key
+	Save(ctx context.Context, key string, rec *IdempotencyRecord) error
+	// Unlock drop the in-progress record, so the request can be retried
+	Unlock(ctx context.Context, key string) error
+}
+
+// IdempotencyConfig idempotency middleware config
+type IdempotencyConfig struct {
+	Store       IdempotencyStore            // default a new MemoryIdempotencyStore
+	TTL         time.Duration               // how long the first response is replayed, default 24h
+	LockTimeout time.Duration               // how long an unfinished request holds the key, default 1m
+	Required    bool                        // reject requests without key with 400
+	Methods     []string                    // default POST and PATCH
+	MaxBody     int64                       // max request body bytes fingerprinted, default 1MB
+	Scope       func(c *gin.Context) string // key scope, default method, route and subject
+}
+
+// Idempotency honour the Idempotency-Key header
+// the first response with a status below 500 is stored and replayed to the duplicates,
+// a duplicate arriving while the first request runs gets 409,
+// the same key with another body gets 422;
+// the per-request headers such as X-Request-ID or RateLimit-* are neither stored nor replayed
+func Idempotency(cfg IdempotencyConfig) gin.HandlerFunc {
+	if cfg.Store == nil {
+		cfg.Store = NewMemoryIdempotencyStore()
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = 24 * time.Hour
+	}
+	if cfg.LockTimeout <= 0 {
+		cfg.LockTimeout = time.Minute
+	}
+	if len(cfg.Methods) == 0 {
+		cfg.Methods = []string{http.MethodPost, http.MethodPatch}
+	}
+	if cfg.MaxBody <= 0 {
+		cfg.MaxBody = 1 << 20
+	}
+	if cfg.Scope == nil {
+		cfg.Scope = func(c *gin.Context) string {
+			return c.Request.Method + " " + c.FullPath() + " " + Claims(c)[SubjectClaim]
+		}
+	}
+	methods := make(map[string]bool, len(cfg.Methods))
+	for _, method := range cfg.Methods {
+		methods[strings.ToUpper(method)] = true
+	}
+
+	return func(c *gin.Context) {
+		if !methods[c.Request.Method] {
+			c.Next()
+			return
+		}
+		id := c.GetHeader(IdempotencyKeyHeader)
+		if id == "" && !cfg.Required {
+			c.Next()
+			return
+		}
+		if id == "" || len(id) > 255 {
+			Fail(c, ErrIdempotencyKey)
+			return
+		}
+		fingerprint, err := bodyFingerprint(c, cfg.MaxBody)
+		if err != nil {
+			Fail(c, err)
+			return
+		}
+
+		ctx := c.Request.Context()
+		key := cfg.Scope(c) + "|" + id
+		rec, locked, err := cfg.Store.Lock(ctx, key, fingerprint, cfg.LockTimeout)
+		if err != nil {
+			Fail(c, err)
+			return
+		}
+		if !locked {
+			switch {
+			case rec.Fingerprint != fingerprint:
+				Fail(c, ErrIdempotencyMismatch)
+			case rec.Status == 0:
+				c.Header("Retry-After", "1")
+				Fail(c, ErrIdempotencyInFlight)
+			default:
+				replay(c, rec)
+			}
+			return
+		}
+
+		w := &teeWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		defer func() {
+			c.Writer = w.ResponseWriter
+			status := w.Status()
+			// a panic or a server error let the client retry
+			if r := recover(); r != nil {
+				_ = cfg.Store.Unlock(context.Background(), key)
+				panic(r)
+			}
+			if status >= http.StatusInternalServerError {
+				_ = cfg.Store.Unlock(context.Background(), key)
+				return
+			}
+			rec := &IdempotencyRecord{
+				Fingerprint: fingerprint,
+				Status:      status,
+				Header:      replayHeader(w.Header()),
+				Body:        w.buf.Bytes(),
+				Expire:      time.Now().Add(cfg.TTL),
+			}
+			if err := cfg.Store.Save(context.Background(), key, rec); err != nil {
+				logger().Warn("idempotency store failed", zap.Error(err))
+			}
+		}()
+		c.Next()
+	}
+}
+
+// bodyFingerprint sha256 of the request body, the body is restored
+func bodyFingerprint(c *gin.Context, maxBody int64) (string, error) {
+	h := sha256.New()
+	if c.Request.Body != nil && c.Request.Body != http.NoBody {
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxBody+1))
+		if err != nil {
+			return "", ErrBadRequest.Wrap(err)
+		}
+		if int64(len(body)) > maxBody {
+			return "", ErrPayloadTooLarge
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		h.Write(body)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// perRequestHeaders response headers of one request, not replayed to the duplicates
+var perRequestHeaders = []string{
+	RequestIDHeader,
+	TraceparentHeader,
+	"Date",
+	"Retry-After",
+	"Set-Cookie",
+	"Content-Length",
+	IdempotentReplayedHeader,
+}
+
+// perRequestPrefixes prefixes of the per-request response headers
+var perRequestPrefixes = []string{"Ratelimit-", "X-Ratelimit-", "Access-Control-"}
+
+// replayHeader the response headers that can be replayed
+func replayHeader(h http.Header) http.Header {
+	out := make(http.Header, len(h))
+	for k, v := range h {
+		if !perRequestHeader(k) {
+			out[k] = append([]string(nil), v...)
+		}
+	}
+	return out
+}
+
+// perRequestHeader report whether the header belongs to one request only
+func perRequestHeader(key string) bool {
+	key = http.CanonicalHeaderKey(key)
+	for _, k := range perRequestHeaders {
+		if key == http.CanonicalHeaderKey(k) {
+			return true
+		}
+	}
+	for _, prefix := range perRequestPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// replay write the stored response, the headers set by the middlewares of the request are kept
+func replay(c *gin.Context, rec *IdempotencyRecord) {
+	h := c.Writer.Header()
+	for k, v := range replayHeader(rec.Header) {
+		if _, ok := h[k]; !ok {
+			h[k] = v
+		}
+	}
+	h.Set(IdempotentReplayedHeader, "true")
+	c.Status(rec.Status)
+	_, _ = c.Writer.Write(rec.Body)
+	c.Abort()
+}
+
+// teeWriter copy the response body
+type teeWriter struct {
+	gin.ResponseWriter
+	buf bytes.Buffer
+}
+
+// Unwrap the wrapped writer, for http.ResponseController
+func (w *teeWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Write write and copy
+func (w *teeWriter) Write(p []byte) (int, error) {
+	w.buf.Write(p)
+	return w.ResponseWriter.Write(p)
+}
+
+// WriteString write and copy
+func (w *teeWriter) WriteString(s string) (int, error) {
+	w.buf.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// MemoryIdempotencyStore in-memory idempotency store
+type MemoryIdempotencyStore struct {
+	mu    sync.Mutex
+	items map[string]*IdempotencyRecord
+	locks int
+}
+
+var _ IdempotencyStore = (*MemoryIdempotencyStore)(nil)
+
+// NewMemoryIdempotencyStore create a memory store
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{items: make(map[string]*IdempotencyRecord)}
+}
+
+// Lock implement IdempotencyStore
+func (s *MemoryIdempotencyStore) Lock(_ context.Context, key, fingerprint string, ttl time.Duration) (*IdempotencyRecord, bool, error) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.locks++
+	if s.locks%1024 == 0 {
+		for k, rec := range s.items {
+			if now.After(rec.Expire) {
+				delete(s.items, k)
+			}
+		}
+	}
+	if rec, ok := s.items[key]; ok && now.Before(rec.Expire) {
+		return rec, false, nil
+	}
+	s.items[key] = &IdempotencyRecord{Fingerprint: fingerprint, Expire: now.Add(ttl)}
+	return nil, true, nil
+}
+
+// Save implement IdempotencyStore
+func (s *MemoryIdempotencyStore) Save(_ context.Context, key string, rec *IdempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items[key] = rec
+	return nil
+}
+
+// Unlock implement IdempotencyStore
+func (s *MemoryIdempotencyStore) Unlock(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.items, key)
+	return nil
+}
+
+// FileIdempotencyStore idempotency store keeping one json file per key in a folder
+// the records survive restarts and can be shared by processes on the same host;
+// an in-progress key is a .lock file created exclusively, a stored response is a .json
+// file renamed into place, so a reader never sees a partial record
+type FileIdempotencyStore struct {
+	dir *filieutil.File
+	mu  sync.Mutex
+}
+
+var _ IdempotencyStore = (*FileIdempotencyStore)(nil)
+
+// NewFileIdempotencyStore create a file store, the folder is created if needed
+func NewFileIdempotencyStore(dir string) (*FileIdempotencyStore, error) {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	f, err := filieutil.New(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !f.IsDir() {
+		return nil, errors.New(dir + " path not a folder")
+	}
+	return &FileIdempotencyStore{dir: f}, nil
+}
+
+// name file name of the key without extension
+func (s *FileIdempotencyStore) name(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Lock implement IdempotencyStore
+// the lock file is created exclusively, so concurrent processes can not lock the same key;
+// a record or a lock is expired only by its stored expire time, a lock file left incomplete
+// by a crash expires ttl after it was written
+func (s *FileIdempotencyStore) Lock(_ context.Context, key, fingerprint string, ttl time.Duration) (*IdempotencyRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	name := s.name(key)
+	for i := 0; i < 2; i++ {
+		rec, err := s.stored(name)
+		if err != nil || rec != nil {
+			return rec, false, err
+		}
+
+		lock := filepath.Join(s.dir.GetPath(), name+".lock")
+		data, err := json.Marshal(&IdempotencyRecord{Fingerprint: fingerprint, Expire: time.Now().Add(ttl)})
+		if err != nil {
+			return nil, false, err
+		}
+		f, err := os.OpenFile(lock, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err == nil {
+			_, err = f.Write(data)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				_ = os.Remove(lock)
+				return nil, false, err
+			}
+			// another process may have saved the response since it was read
+			if rec, err := s.stored(name); err != nil || rec != nil {
+				_ = os.Remove(lock)
+				return rec, false, err
+			}
+			return nil, true, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, false, err
+		}
+
+		rec, expire, err := readLock(lock, ttl)
+		if errors.Is(err, os.ErrNotExist) {
+			// unlocked or saved meanwhile
+			continue
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		if time.Now().Before(expire) {
+			if rec == nil {
+				// still being written, the fingerprint is not known yet
+				rec = &IdempotencyRecord{Fingerprint: fingerprint, Expire: expire}
+			}
+			return rec, false, nil
+		}
+		// the request holding the lock died, take it over
+		if err := os.Remove(lock); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, false, err
+		}
+	}
+	return nil, false, errors.New("idempotency key " + key + " can not be locked")
+}
+
+// stored the unexpired response of the key, an expired one is removed
+func (s *FileIdempotencyStore) stored(name string) (*IdempotencyRecord, error) {
+	path := filepath.Join(s.dir.GetPath(), name+".json")
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	rec := &IdempotencyRecord{}
+	if err := json.Unmarshal(data, rec); err != nil {
+		return nil, errors.New("idempotency record " + path + " is corrupted: " + err.Error())
+	}
+	if time.Now().Before(rec.Expire) {
+		return rec, nil
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// readLock read a lock file and its expire time
+// a lock file still being written has no record and expires ttl after its modification time
+func readLock(path string, ttl time.Duration) (*IdempotencyRecord, time.Time, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	rec := &IdempotencyRecord{}
+	if err := json.Unmarshal(data, rec); err == nil {
+		return rec, rec.Expire, nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return nil, info.ModTime().Add(ttl), nil
+}
+
+// Save implement IdempotencyStore
+// the record is written to a temp file renamed into place, then the lock is released
+func (s *FileIdempotencyStore) Save(_ context.Context, key string, rec *IdempotencyRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	name := s.name(key)
+	f, err := os.CreateTemp(s.dir.GetPath(), name+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if serr := f.Sync(); err == nil {
+		err = serr
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filepath.Join(s.dir.GetPath(), name+".json"))
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+	if err := os.Remove(filepath.Join(s.dir.GetPath(), name+".lock")); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Unlock implement IdempotencyStore
+func (s *FileIdempotencyStore) Unlock(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.Remove(filepath.Join(s.dir.GetPath(), s.name(key)+".lock")); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Sweep remove the expired records and locks, run it from time to time
+// unreadable records are kept, temp files are removed an hour after their last write
+func (s *FileIdempotencyStore) Sweep() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	children, err := s.dir.GetChildren()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, child := range children {
+		if child.IsDir() {
+			continue
+		}
+		expired := false
+		switch child.GetSuffix() {
+		case ".json", ".lock":
+			data, err := os.ReadFile(child.GetPath())
+			if err != nil {
+				continue
+			}
+			rec := &IdempotencyRecord{}
+			expired = json.Unmarshal(data, rec) == nil && now.After(rec.Expire)
+		case ".tmp":
+			info, err := os.Stat(child.GetPath())
+			expired = err == nil && now.Sub(info.ModTime()) > time.Hour
+		}
+		if !expired {
+			continue
+		}
+		if err := child.Remove(); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
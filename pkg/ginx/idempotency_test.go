@@ -0,0 +1,176 @@
+package ginx
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// idempotencyStores the stores under test
+func idempotencyStores(t *testing.T) map[string]IdempotencyStore {
+	fs, err := NewFileIdempotencyStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return map[string]IdempotencyStore{"memory": NewMemoryIdempotencyStore(), "file": fs}
+}
+
+func TestIdempotency(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	type step struct {
+		key      string
+		body     string
+		status   int
+		replayed bool
+	}
+	tests := []struct {
+		name  string
+		fail  bool // the handler fails with 500 on its first run
+		steps []step
+	}{
+		{"replay", false, []step{
+			{"k1", `{"n":1}`, http.StatusCreated, false},
+			{"k1", `{"n":1}`, http.StatusCreated, true},
+			{"k2", `{"n":1}`, http.StatusCreated, false},
+		}},
+		{"other body", false, []step{
+			{"k1", `{"n":1}`, http.StatusCreated, false},
+			{"k1", `{"n":2}`, http.StatusUnprocessableEntity, false},
+		}},
+		{"server error is retried", true, []step{
+			{"k1", `{"n":1}`, http.StatusInternalServerError, false},
+			{"k1", `{"n":1}`, http.StatusCreated, false},
+			{"k1", `{"n":1}`, http.StatusCreated, true},
+		}},
+		{"missing key", false, []step{
+			{"", `{"n":1}`, http.StatusBadRequest, false},
+		}},
+	}
+	for _, tt := range tests {
+		for name, store := range idempotencyStores(t) {
+			t.Run(tt.name+"/"+name, func(t *testing.T) {
+				var runs int64
+				e := gin.New()
+				e.Use(func(c *gin.Context) {
+					c.Header(RequestIDHeader, "req-"+c.GetHeader("X-Step"))
+				})
+				e.POST("/orders", Idempotency(IdempotencyConfig{Store: store, Required: true}), func(c *gin.Context) {
+					n := atomic.AddInt64(&runs, 1)
+					if tt.fail && n == 1 {
+						Fail(c, ErrInternal)
+						return
+					}
+					c.Header("Location", "/orders/"+strconv.FormatInt(n, 10))
+					c.Header("RateLimit-Remaining", "9")
+					c.String(http.StatusCreated, "order %d", n)
+				})
+
+				var first string
+				for i, s := range tt.steps {
+					req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(s.body))
+					req.Header.Set("X-Step", strconv.Itoa(i))
+					if s.key != "" {
+						req.Header.Set(IdempotencyKeyHeader, s.key)
+					}
+					w := httptest.NewRecorder()
+					e.ServeHTTP(w, req)
+					if w.Code != s.status {
+						t.Fatalf("step %d: status %d, want %d: %s", i, w.Code, s.status, w.Body.String())
+					}
+					if got := w.Header().Get(IdempotentReplayedHeader) == "true"; got != s.replayed {
+						t.Fatalf("step %d: replayed %v, want %v", i, got, s.replayed)
+					}
+					if got := w.Header().Get(RequestIDHeader); got != "req-"+strconv.Itoa(i) {
+						t.Fatalf("step %d: %s %q is not the one of the request", i, RequestIDHeader, got)
+					}
+					if w.Code != http.StatusCreated {
+						continue
+					}
+					if !s.replayed {
+						first = w.Body.String()
+						continue
+					}
+					if w.Body.String() != first || w.Header().Get("Location") == "" {
+						t.Fatalf("step %d: replayed %q %q, want %q", i, w.Body.String(), w.Header().Get("Location"), first)
+					}
+					if got := w.Header().Get("RateLimit-Remaining"); got != "" {
+						t.Fatalf("step %d: per-request RateLimit-Remaining %q replayed", i, got)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestIdempotencyInFlight(t *testing.T) {
+	for name, store := range idempotencyStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			if _, locked, err := store.Lock(ctx, "k", "fp", time.Minute); err != nil || !locked {
+				t.Fatalf("first Lock = %v, %v", locked, err)
+			}
+			rec, locked, err := store.Lock(ctx, "k", "fp", time.Minute)
+			if err != nil || locked || rec.Status != 0 || rec.Fingerprint != "fp" {
+				t.Fatalf("second Lock = %+v, %v, %v", rec, locked, err)
+			}
+			if err := store.Unlock(ctx, "k"); err != nil {
+				t.Fatal(err)
+			}
+			if _, locked, err := store.Lock(ctx, "k", "fp", time.Minute); err != nil || !locked {
+				t.Fatalf("Lock after Unlock = %v, %v", locked, err)
+			}
+		})
+	}
+}
+
+func TestFileIdempotencyStoreDamagedFiles(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name   string
+		ext    string
+		data   string
+		age    time.Duration
+		locked bool
+		err    bool
+	}{
+		{"lock being written", ".lock", `{"finger`, 0, false, false},
+		{"lock left by a crash", ".lock", "", 2 * time.Minute, true, false},
+		{"expired lock", ".lock", `{"fingerprint":"fp","expire":"2000-01-01T00:00:00Z"}`, 0, true, false},
+		{"corrupted record", ".json", `{"status":`, 2 * time.Minute, false, true},
+		{"expired record", ".json", `{"fingerprint":"fp","status":201,"expire":"2000-01-01T00:00:00Z"}`, 0, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := NewFileIdempotencyStore(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join(store.dir.GetPath(), store.name("k")+tt.ext)
+			if err := os.WriteFile(path, []byte(tt.data), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if tt.age > 0 {
+				old := time.Now().Add(-tt.age)
+				if err := os.Chtimes(path, old, old); err != nil {
+					t.Fatal(err)
+				}
+			}
+			_, locked, err := store.Lock(ctx, "k", "fp", time.Minute)
+			if (err != nil) != tt.err || locked != tt.locked {
+				t.Fatalf("Lock = %v, %v, want locked %v and error %v", locked, err, tt.locked, tt.err)
+			}
+			if _, err := os.Stat(path); tt.err && err != nil {
+				t.Fatalf("corrupted record removed: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package ginx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// RouterNamer router with a module name, default the type name
+type RouterNamer interface {
+	Name() string
+}
+
+// RouterDepender router depending on other routers by name
+// the dependencies are executed and started first, and stopped last
+type RouterDepender interface {
+	DependsOn() []string
+}
+
+// RouterStarter router initializing resources before the server starts serving
+type RouterStarter interface {
+	OnStart(ctx context.Context) error
+}
+
+// RouterStopper router releasing resources on graceful shutdown
+type RouterStopper interface {
+	OnStop(ctx context.Context) error
+}
+
+// unwrapRouter the value implementing the lifecycle interfaces
+// group routers and controllers are wrapped by ginx
+func unwrapRouter(r Router) any {
+	switch v := r.(type) {
+	case groupRouter:
+		return v.GroupRouter
+	case *controllerRouter:
+		return v.ctrl
+	}
+	return r
+}
+
+// routerName name of the router
+func routerName(r Router) string {
+	inner := unwrapRouter(r)
+	if n, ok := inner.(RouterNamer); ok && n.Name() != "" {
+		return n.Name()
+	}
+	if inner == nil {
+		return "<nil>"
+	}
+	return reflect.Indirect(reflect.ValueOf(inner)).Type().Name()
+}
+
+// sortRouters order the routers so every router comes after its dependencies
+// routers without dependencies between them keep the insertion order
+func sortRouters(routers []Router) ([]Router, error) {
+	names := make([]string, len(routers))
+	index := make(map[string]int, len(routers))
+	for i, r := range routers {
+		names[i] = routerName(r)
+		_, explicit := unwrapRouter(r).(RouterNamer)
+		if j, ok := index[names[i]]; ok {
+			if explicit {
+				return nil, fmt.Errorf("duplicate router name %q", names[i])
+			}
+			if j >= 0 {
+				index[names[i]] = -1 // several routers of the same type, can not be depended on
+			}
+			continue
+		}
+		index[names[i]] = i
+	}
+
+	deps := make([][]int, len(routers))
+	for i, r := range routers {
+		d, ok := unwrapRouter(r).(RouterDepender)
+		if !ok {
+			continue
+		}
+		for _, name := range d.DependsOn() {
+			j, ok := index[name]
+			switch {
+			case !ok:
+				return nil, fmt.Errorf("router %q depends on missing router %q", names[i], name)
+			case j < 0:
+				return nil, fmt.Errorf("router %q depends on %q, which names several routers", names[i], name)
+			}
+			deps[i] = append(deps[i], j)
+		}
+	}
+
+	// depth first in insertion order, the path is kept to report cycles
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make([]int, len(routers))
+	sorted := make([]Router, 0, len(routers))
+	path := make([]int, 0)
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch state[i] {
+		case done:
+			return nil
+		case visiting:
+			cycle := make([]string, 0)
+			for k := len(path) - 1; k >= 0; k-- {
+				cycle = append([]string{names[path[k]]}, cycle...)
+				if path[k] == i {
+					break
+				}
+			}
+			return fmt.Errorf("router dependency cycle: %s -> %s", strings.Join(cycle, " -> "), names[i])
+		}
+		state[i] = visiting
+		path = append(path, i)
+		for _, j := range deps[i] {
+			if err := visit(j); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[i] = done
+		sorted = append(sorted, routers[i])
+		return nil
+	}
+	for i := range routers {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
+
+// Start call OnStart of the routers in dependency order
+// if one fails the started ones are stopped in reverse order
+func (a *App) Start(ctx context.Context) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.started != nil {
+		return nil
+	}
+	sorted, err := sortRouters(a.routers)
+	if err != nil {
+		return err
+	}
+	a.started = make([]Router, 0, len(sorted))
+	for _, r := range sorted {
+		if s, ok := unwrapRouter(r).(RouterStarter); ok {
+			if err := s.OnStart(ctx); err != nil {
+				err = fmt.Errorf("start router %s: %w", routerName(r), err)
+				return errors.Join(err, a.stop(ctx))
+			}
+		}
+		a.started = append(a.started, r)
+	}
+	return nil
+}
+
+// Stop call OnStop of the started routers in reverse order
+func (a *App) Stop(ctx context.Context) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.stop(ctx)
+}
+
+// stop stop the started routers, a.mu is held
+func (a *App) stop(ctx context.Context) error {
+	errs := make([]error, 0)
+	for i := len(a.started) - 1; i >= 0; i-- {
+		r := a.started[i]
+		if s, ok := unwrapRouter(r).(RouterStopper); ok {
+			if err := s.OnStop(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("stop router %s: %w", routerName(r), err))
+			}
+		}
+	}
+	a.started = nil
+	return errors.Join(errs...)
+}
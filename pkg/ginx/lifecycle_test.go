@@ -0,0 +1,122 @@
+package ginx
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// moduleRouter router recording its lifecycle calls
+type moduleRouter struct {
+	name    string
+	deps    []string
+	fail    bool // OnStart fails
+	journal *[]string
+}
+
+func (r *moduleRouter) Name() string        { return r.name }
+func (r *moduleRouter) DependsOn() []string { return r.deps }
+
+func (r *moduleRouter) Execute(*gin.Engine) {
+	*r.journal = append(*r.journal, "execute "+r.name)
+}
+
+func (r *moduleRouter) OnStart(context.Context) error {
+	if r.fail {
+		return errors.New("boom")
+	}
+	*r.journal = append(*r.journal, "start "+r.name)
+	return nil
+}
+
+func (r *moduleRouter) OnStop(context.Context) error {
+	*r.journal = append(*r.journal, "stop "+r.name)
+	return nil
+}
+
+func TestRouterDependencies(t *testing.T) {
+	tests := []struct {
+		name    string
+		modules [][]string // name then dependencies
+		order   []string   // execute order
+		err     string     // part of the error, empty for none
+	}{
+		{"insertion order", [][]string{{"a"}, {"b"}, {"c"}}, []string{"a", "b", "c"}, ""},
+		{"dependencies first", [][]string{{"api", "db", "cache"}, {"cache", "db"}, {"db"}}, []string{"db", "cache", "api"}, ""},
+		{"missing module", [][]string{{"api", "db"}}, nil, `router "api" depends on missing router "db"`},
+		{"cycle", [][]string{{"a", "b"}, {"b", "c"}, {"c", "a"}}, nil, "router dependency cycle: a -> b -> c -> a"},
+		{"self dependency", [][]string{{"a", "a"}}, nil, "router dependency cycle: a -> a"},
+		{"duplicate name", [][]string{{"a"}, {"a"}}, nil, `duplicate router name "a"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			journal := make([]string, 0)
+			a := New(nil)
+			for _, m := range tt.modules {
+				a.AddRouters(&moduleRouter{name: m[0], deps: m[1:], journal: &journal})
+			}
+			err := a.RouterExecuteE()
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("error %v, want %q", err, tt.err)
+				}
+				if len(journal) != 0 {
+					t.Fatalf("routers executed despite the error: %v", journal)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := make([]string, 0, len(tt.order))
+			for _, name := range tt.order {
+				want = append(want, "execute "+name)
+			}
+			if !reflect.DeepEqual(journal, want) {
+				t.Fatalf("order %v, want %v", journal, want)
+			}
+		})
+	}
+}
+
+func TestRouterLifecycle(t *testing.T) {
+	journal := make([]string, 0)
+	a := New(nil)
+	a.AddRouters(
+		&moduleRouter{name: "api", deps: []string{"db"}, journal: &journal},
+		&moduleRouter{name: "db", journal: &journal},
+	)
+	ctx := context.Background()
+	if err := a.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Stop(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"start db", "start api", "stop api", "stop db"}; !reflect.DeepEqual(journal, want) {
+		t.Fatalf("lifecycle %v, want %v", journal, want)
+	}
+
+	// a failed start stops the started routers in reverse order
+	journal = journal[:0]
+	a = New(nil)
+	a.AddRouters(
+		&moduleRouter{name: "db", journal: &journal},
+		&moduleRouter{name: "cache", journal: &journal},
+		&moduleRouter{name: "api", deps: []string{"db", "cache"}, fail: true, journal: &journal},
+	)
+	err := a.Start(ctx)
+	if err == nil || !strings.Contains(err.Error(), "start router api: boom") {
+		t.Fatalf("error %v, want the api start error", err)
+	}
+	if want := []string{"start db", "start cache", "stop cache", "stop db"}; !reflect.DeepEqual(journal, want) {
+		t.Fatalf("lifecycle %v, want %v", journal, want)
+	}
+}
@@ -0,0 +1,28 @@
+package ginx
+
+import (
+	"os"
+	"sync"
+
+	"github.com/miajio/gin-screw/pkg/log"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+var (
+	stderrLogger     *zap.Logger
+	stderrLoggerOnce sync.Once
+)
+
+// logger the pkg/log logger, or a stderr logger when log.Init is not called
+// so the middleware never lose a line
+func logger() *zap.Logger {
+	if log.IsInit() {
+		return log.GetLogger().Desugar()
+	}
+	stderrLoggerOnce.Do(func() {
+		core := zapcore.NewCore(zapcore.NewConsoleEncoder(zap.NewDevelopmentEncoderConfig()), zapcore.Lock(os.Stderr), zap.DebugLevel)
+		stderrLogger = zap.New(core)
+	})
+	return stderrLogger
+}
@@ -0,0 +1,311 @@
+package ginx
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/miajio/gin-screw/pkg/jwt"
+	"github.com/miajio/gin-screw/pkg/log"
+)
+
+// MetricsConfig metrics config
+type MetricsConfig struct {
+	Namespace   string    // metric name prefix, default ginx
+	Buckets     []float64 // latency buckets in seconds
+	SizeBuckets []float64 // response size buckets in bytes
+}
+
+var (
+	defaultBuckets     = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+	defaultSizeBuckets = []float64{100, 1000, 10000, 100000, 1e6, 1e7}
+)
+
+// Collector write extra metrics in the Prometheus text format
+type Collector interface {
+	WriteMetrics(w io.Writer, namespace string)
+}
+
+// Metrics http metrics exposed in the Prometheus text format
+type Metrics struct {
+	cfg        MetricsConfig
+	inFlight   int64
+	mu         sync.RWMutex
+	series     map[metricLabels]*httpSeries
+	collectors []Collector
+}
+
+// metricLabels labels of the http series
+type metricLabels struct {
+	route  string
+	method string
+	status string
+}
+
+// httpSeries metrics of one label set
+type httpSeries struct {
+	mu       sync.Mutex
+	requests uint64
+	latency  *histogram
+	size     *histogram
+}
+
+// histogram cumulative histogram
+type histogram struct {
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+// NewMetrics create the metrics, the pkg/log lines, pkg/jwt failures and shed requests are collected too
+func NewMetrics(cfg MetricsConfig) *Metrics {
+	if cfg.Namespace == "" {
+		cfg.Namespace = "ginx"
+	}
+	if len(cfg.Buckets) == 0 {
+		cfg.Buckets = defaultBuckets
+	}
+	if len(cfg.SizeBuckets) == 0 {
+		cfg.SizeBuckets = defaultSizeBuckets
+	}
+	return &Metrics{
+		cfg:        cfg,
+		series:     make(map[metricLabels]*httpSeries),
+		collectors: []Collector{logCollector{}, jwtCollector{}, shedCollector{}},
+	}
+}
+
+// Register add a collector
+func (m *Metrics) Register(c Collector) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.collectors = append(m.collectors, c)
+}
+
+// Middleware collect request count, latency, in-flight requests and response size
+// labelled by route template, method and status
+func (m *Metrics) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		atomic.AddInt64(&m.inFlight, 1)
+		defer atomic.AddInt64(&m.inFlight, -1)
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		size := c.Writer.Size()
+		if size < 0 {
+			size = 0
+		}
+		s := m.get(metricLabels{route: route, method: c.Request.Method, status: strconv.Itoa(c.Writer.Status())})
+		s.mu.Lock()
+		s.requests++
+		s.latency.observe(time.Since(start).Seconds())
+		s.size.observe(float64(size))
+		s.mu.Unlock()
+	}
+}
+
+// get the series of the labels
+func (m *Metrics) get(labels metricLabels) *httpSeries {
+	m.mu.RLock()
+	s, ok := m.series[labels]
+	m.mu.RUnlock()
+	if ok {
+		return s
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s, ok = m.series[labels]; !ok {
+		s = &httpSeries{latency: newHistogram(m.cfg.Buckets), size: newHistogram(m.cfg.SizeBuckets)}
+		m.series[labels] = s
+	}
+	return s
+}
+
+// Handler serve the metrics
+func (m *Metrics) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		c.Status(http.StatusOK)
+		m.WriteTo(c.Writer)
+	}
+}
+
+// WriteTo write all the metrics in the Prometheus text format
+func (m *Metrics) WriteTo(out io.Writer) (int64, error) {
+	bw := bufio.NewWriter(out)
+	w := &countWriter{w: bw}
+	ns := m.cfg.Namespace
+
+	m.mu.RLock()
+	labels := make([]metricLabels, 0, len(m.series))
+	for l := range m.series {
+		labels = append(labels, l)
+	}
+	collectors := append([]Collector(nil), m.collectors...)
+	m.mu.RUnlock()
+	sort.Slice(labels, func(i, j int) bool {
+		a, b := labels[i], labels[j]
+		if a.route != b.route {
+			return a.route < b.route
+		}
+		if a.method != b.method {
+			return a.method < b.method
+		}
+		return a.status < b.status
+	})
+
+	writeHeader(w, ns+"_http_requests_total", "counter", "Total http requests.")
+	for _, l := range labels {
+		s := m.get(l)
+		s.mu.Lock()
+		fmt.Fprintf(w, "%s_http_requests_total{%s} %d\n", ns, l, s.requests)
+		s.mu.Unlock()
+	}
+	writeHeader(w, ns+"_http_request_duration_seconds", "histogram", "Http request latency in seconds.")
+	for _, l := range labels {
+		s := m.get(l)
+		s.mu.Lock()
+		s.latency.write(w, ns+"_http_request_duration_seconds", l.String())
+		s.mu.Unlock()
+	}
+	writeHeader(w, ns+"_http_response_size_bytes", "histogram", "Http response size in bytes.")
+	for _, l := range labels {
+		s := m.get(l)
+		s.mu.Lock()
+		s.size.write(w, ns+"_http_response_size_bytes", l.String())
+		s.mu.Unlock()
+	}
+	writeHeader(w, ns+"_http_requests_in_flight", "gauge", "Http requests being served.")
+	fmt.Fprintf(w, "%s_http_requests_in_flight %d\n", ns, atomic.LoadInt64(&m.inFlight))
+
+	for _, c := range collectors {
+		c.WriteMetrics(w, ns)
+	}
+	err := bw.Flush()
+	return w.n, err
+}
+
+// EnableMetrics collect the metrics of every request and serve them on path, default /metrics
+// the gauges of the limiters added with AddLimiters are served too
+func (a *App) EnableMetrics(path string, cfg MetricsConfig) *Metrics {
+	if path == "" {
+		path = "/metrics"
+	}
+	m := NewMetrics(cfg)
+	m.Register(limiterCollector{a})
+	a.Use(m.Middleware())
+	a.Engine().GET(path, m.Handler())
+	return m
+}
+
+// EnableMetrics enable the metrics of the singleton app
+func EnableMetrics(path string, cfg MetricsConfig) *Metrics {
+	check()
+	return this.EnableMetrics(path, cfg)
+}
+
+// String labels in the exposition format
+func (l metricLabels) String() string {
+	return fmt.Sprintf(`method="%s",route="%s",status="%s"`, escapeLabel(l.method), escapeLabel(l.route), l.status)
+}
+
+// newHistogram create a histogram
+func newHistogram(buckets []float64) *histogram {
+	return &histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+}
+
+// observe add a value
+func (h *histogram) observe(v float64) {
+	for i, b := range h.buckets {
+		if v <= b {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// write the histogram series
+func (h *histogram) write(w io.Writer, name, labels string) {
+	for i, b := range h.buckets {
+		fmt.Fprintf(w, "%s_bucket{%s,le=\"%s\"} %d\n", name, labels, formatFloat(b), h.counts[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, h.count)
+	fmt.Fprintf(w, "%s_sum{%s} %s\n", name, labels, formatFloat(h.sum))
+	fmt.Fprintf(w, "%s_count{%s} %d\n", name, labels, h.count)
+}
+
+// writeHeader write the HELP and TYPE lines
+func writeHeader(w io.Writer, name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// WriteCounters write a counter family with one label, sorted by label value
+func WriteCounters(w io.Writer, name, help, label string, values map[string]uint64) {
+	writeHeader(w, name, "counter", help)
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(w, "%s{%s=\"%s\"} %d\n", name, label, escapeLabel(k), values[k])
+	}
+}
+
+// escapeLabel escape a label value
+func escapeLabel(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+// formatFloat float in the exposition format
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// logCollector lines written by pkg/log per level
+type logCollector struct{}
+
+// WriteMetrics implement Collector
+func (logCollector) WriteMetrics(w io.Writer, namespace string) {
+	WriteCounters(w, namespace+"_log_lines_total", "Log lines written by pkg/log per level.", "level", log.Counts())
+}
+
+// jwtCollector pkg/jwt validation failures per reason
+type jwtCollector struct{}
+
+// WriteMetrics implement Collector
+func (jwtCollector) WriteMetrics(w io.Writer, namespace string) {
+	WriteCounters(w, namespace+"_jwt_failures_total", "Token validation failures of pkg/jwt per reason.", "reason", jwt.FailureCounts())
+}
+
+// countWriter count the written bytes
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+// Write count the bytes
+func (cw *countWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,87 @@
+package ginx
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// countCollector collector with fixed counters
+type countCollector map[string]uint64
+
+// WriteMetrics implement Collector
+func (c countCollector) WriteMetrics(w io.Writer, namespace string) {
+	WriteCounters(w, namespace+"_jobs_total", "Jobs per queue.", "queue", c)
+}
+
+func TestMetrics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := NewMetrics(MetricsConfig{Namespace: "app", Buckets: []float64{10}, SizeBuckets: []float64{1, 10}})
+	m.Register(countCollector{"mail": 2, `a"b`: 1})
+	e := gin.New()
+	e.Use(m.Middleware())
+	e.GET("/users/:id", func(c *gin.Context) { c.String(http.StatusOK, "user "+c.Param("id")) })
+	e.GET("/metrics", m.Handler())
+
+	for _, path := range []string{"/users/1", "/users/22", "/nowhere"} {
+		e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Fatalf("content type %q", ct)
+	}
+	body := w.Body.String()
+
+	users := `method="GET",route="/users/:id",status="200"`
+	unmatched := `method="GET",route="unmatched",status="404"`
+	for _, line := range []string{
+		"# HELP app_http_requests_total Total http requests.",
+		"# TYPE app_http_requests_total counter",
+		"app_http_requests_total{" + users + "} 2",
+		"app_http_requests_total{" + unmatched + "} 1",
+		"# TYPE app_http_request_duration_seconds histogram",
+		"app_http_request_duration_seconds_bucket{" + users + `,le="10"} 2`,
+		"app_http_request_duration_seconds_bucket{" + users + `,le="+Inf"} 2`,
+		"app_http_request_duration_seconds_count{" + users + "} 2",
+		// "user 1" and "user 22" are 6 and 7 bytes
+		"app_http_response_size_bytes_bucket{" + users + `,le="1"} 0`,
+		"app_http_response_size_bytes_bucket{" + users + `,le="10"} 2`,
+		"app_http_response_size_bytes_bucket{" + users + `,le="+Inf"} 2`,
+		"app_http_response_size_bytes_sum{" + users + "} 13",
+		"app_http_response_size_bytes_count{" + users + "} 2",
+		// the scrape itself is in flight
+		"# TYPE app_http_requests_in_flight gauge",
+		"app_http_requests_in_flight 1",
+		"# TYPE app_log_lines_total counter",
+		"# TYPE app_jwt_failures_total counter",
+		`app_jobs_total{queue="a\"b"} 1`,
+		`app_jobs_total{queue="mail"} 2`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("missing line %q", line)
+		}
+	}
+	// the series are sorted by route
+	if strings.Index(body, "app_http_requests_total{"+users) > strings.Index(body, "app_http_requests_total{"+unmatched) {
+		t.Error("series not sorted")
+	}
+	if t.Failed() {
+		t.Log(body)
+	}
+
+	var sb strings.Builder
+	n, err := m.WriteTo(&sb)
+	if err != nil || n != int64(sb.Len()) {
+		t.Fatalf("WriteTo %d, %v, wrote %d", n, err, sb.Len())
+	}
+	// the first scrape is counted now
+	if want := fmt.Sprintf("app_http_requests_total{%s} 1\n", `method="GET",route="/metrics",status="200"`); !strings.Contains(sb.String(), want) {
+		t.Fatalf("scrape not counted:\n%s", sb.String())
+	}
+}
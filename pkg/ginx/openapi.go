@@ -0,0 +1,544 @@
+package ginx
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/miajio/gin-screw/pkg/validate"
+	"gopkg.in/yaml.v3"
+)
+
+// OpenAPIInfo document info
+type OpenAPIInfo struct {
+	Title       string   // api title
+	Version     string   // api version
+	Description string   // api description
+	Servers     []string // server urls
+}
+
+// OpenAPIDoc OpenAPI 3 document
+type OpenAPIDoc struct {
+	OpenAPI    string                           `json:"openapi" yaml:"openapi"`
+	Info       openAPIInfo                      `json:"info" yaml:"info"`
+	Servers    []openAPIServer                  `json:"servers,omitempty" yaml:"servers,omitempty"`
+	Paths      map[string]map[string]*OpenAPIOp `json:"paths" yaml:"paths"`
+	Components openAPIComponents                `json:"components" yaml:"components"`
+}
+
+type openAPIInfo struct {
+	Title       string `json:"title" yaml:"title"`
+	Version     string `json:"version" yaml:"version"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+}
+
+type openAPIServer struct {
+	URL string `json:"url" yaml:"url"`
+}
+
+type openAPIComponents struct {
+	Schemas map[string]*Schema `json:"schemas,omitempty" yaml:"schemas,omitempty"`
+}
+
+// OpenAPIOp OpenAPI operation
+type OpenAPIOp struct {
+	OperationID string                      `json:"operationId,omitempty" yaml:"operationId,omitempty"`
+	Summary     string                      `json:"summary,omitempty" yaml:"summary,omitempty"`
+	Description string                      `json:"description,omitempty" yaml:"description,omitempty"`
+	Tags        []string                    `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Parameters  []*Parameter                `json:"parameters,omitempty" yaml:"parameters,omitempty"`
+	RequestBody *RequestBody                `json:"requestBody,omitempty" yaml:"requestBody,omitempty"`
+	Responses   map[string]*OpenAPIResponse `json:"responses" yaml:"responses"`
+}
+
+// Parameter OpenAPI parameter
+type Parameter struct {
+	Name     string  `json:"name" yaml:"name"`
+	In       string  `json:"in" yaml:"in"` // path, query or header
+	Required bool    `json:"required,omitempty" yaml:"required,omitempty"`
+	Schema   *Schema `json:"schema" yaml:"schema"`
+}
+
+// RequestBody OpenAPI request body
+type RequestBody struct {
+	Required bool                  `json:"required,omitempty" yaml:"required,omitempty"`
+	Content  map[string]*MediaType `json:"content" yaml:"content"`
+}
+
+// OpenAPIResponse OpenAPI response
+type OpenAPIResponse struct {
+	Description string                `json:"description" yaml:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty" yaml:"content,omitempty"`
+}
+
+// MediaType OpenAPI media type
+type MediaType struct {
+	Schema *Schema `json:"schema" yaml:"schema"`
+}
+
+// Schema OpenAPI schema
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty" yaml:"$ref,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty" yaml:"allOf,omitempty"`
+	Type                 string             `json:"type,omitempty" yaml:"type,omitempty"`
+	Format               string             `json:"format,omitempty" yaml:"format,omitempty"`
+	Items                *Schema            `json:"items,omitempty" yaml:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty" yaml:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty" yaml:"additionalProperties,omitempty"`
+	Required             []string           `json:"required,omitempty" yaml:"required,omitempty"`
+	Enum                 []any              `json:"enum,omitempty" yaml:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty" yaml:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty" yaml:"maximum,omitempty"`
+	ExclusiveMinimum     bool               `json:"exclusiveMinimum,omitempty" yaml:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum     bool               `json:"exclusiveMaximum,omitempty" yaml:"exclusiveMaximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty" yaml:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty" yaml:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty" yaml:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty" yaml:"maxItems,omitempty"`
+	Pattern              string             `json:"pattern,omitempty" yaml:"pattern,omitempty"`
+	Validate             []string           `json:"x-validate,omitempty" yaml:"x-validate,omitempty"` // custom validate tags
+}
+
+// limiterPatterns patterns of the pkg/validate limiters
+var limiterPatterns = map[uintptr]string{
+	funcPointer(validate.EnglishLimiter): `^[A-Za-z]*$`,
+	funcPointer(validate.IntegerLimiter): `^[+-]?\d+$`,
+	funcPointer(validate.NumberLimiter):  `^[+-]?(\d+\.?\d*|\.\d+)([eE][+-]?\d+)?$`,
+}
+
+// funcPointer code pointer of the validator func
+func funcPointer(fn validator.Func) uintptr {
+	return reflect.ValueOf(fn).Pointer()
+}
+
+// builtinTags validator tags that are not custom ones
+var builtinTags = map[string]bool{
+	"omitempty": true, "required": true, "dive": true, "keys": true, "endkeys": true,
+	"min": true, "max": true, "len": true, "gt": true, "gte": true, "lt": true, "lte": true,
+	"eq": true, "ne": true, "oneof": true,
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// openAPIBuilder build the document schemas
+type openAPIBuilder struct {
+	schemas map[string]*Schema
+	names   map[reflect.Type]string
+}
+
+// OpenAPI build the OpenAPI 3 document of the registered routes
+func (a *App) OpenAPI(info OpenAPIInfo) *OpenAPIDoc {
+	doc := &OpenAPIDoc{
+		OpenAPI: "3.0.3",
+		Info:    openAPIInfo{Title: info.Title, Version: info.Version, Description: info.Description},
+		Paths:   make(map[string]map[string]*OpenAPIOp),
+	}
+	if doc.Info.Title == "" {
+		doc.Info.Title = "API"
+	}
+	if doc.Info.Version == "" {
+		doc.Info.Version = "1.0.0"
+	}
+	for _, server := range info.Servers {
+		doc.Servers = append(doc.Servers, openAPIServer{URL: server})
+	}
+
+	b := &openAPIBuilder{schemas: make(map[string]*Schema), names: make(map[reflect.Type]string)}
+	for _, route := range a.Engine().Routes() {
+		path := openAPIPath(route.Path)
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = make(map[string]*OpenAPIOp)
+		}
+		doc.Paths[path][strings.ToLower(route.Method)] = b.operation(route, a.operation(route.Method, route.Path))
+	}
+	doc.Components.Schemas = b.schemas
+	return doc
+}
+
+// ServeOpenAPI serve the OpenAPI document on path
+// the document is yaml when the path ends with .yaml or .yml, the format query is yaml
+// or the Accept header asks for yaml, otherwise json
+func (a *App) ServeOpenAPI(path string, info OpenAPIInfo) {
+	a.Engine().GET(path, func(c *gin.Context) {
+		doc := a.OpenAPI(info)
+		delete(doc.Paths, openAPIPath(c.FullPath()))
+		if strings.HasSuffix(path, ".yaml") || strings.HasSuffix(path, ".yml") ||
+			c.Query("format") == "yaml" || strings.Contains(c.GetHeader("Accept"), "yaml") {
+			out, err := yaml.Marshal(doc)
+			if err != nil {
+				c.AbortWithError(http.StatusInternalServerError, err)
+				return
+			}
+			c.Data(http.StatusOK, "application/yaml; charset=utf-8", out)
+			return
+		}
+		c.JSON(http.StatusOK, doc)
+	})
+}
+
+// ServeOpenAPI serve the OpenAPI document of the singleton app
+func ServeOpenAPI(path string, info OpenAPIInfo) {
+	check()
+	this.ServeOpenAPI(path, info)
+}
+
+// openAPIPath gin path to OpenAPI path: /users/:id -> /users/{id}
+func openAPIPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		if strings.HasPrefix(s, ":") || strings.HasPrefix(s, "*") {
+			segments[i] = "{" + s[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// operation build the operation of the route with its document, nil if it has none
+func (b *openAPIBuilder) operation(route gin.RouteInfo, doc *Operation) *OpenAPIOp {
+	op := &OpenAPIOp{Responses: map[string]*OpenAPIResponse{
+		"200": {Description: http.StatusText(http.StatusOK)},
+	}}
+	if doc == nil {
+		doc = &Operation{}
+	}
+	op.OperationID, op.Summary, op.Description, op.Tags = doc.OperationID, doc.Summary, doc.Description, doc.Tags
+
+	params := make(map[string]bool)
+	if req := doc.requestType(); req != nil {
+		body := b.request(req, route.Method, op, params)
+		if body != nil {
+			op.RequestBody = &RequestBody{Required: true, Content: map[string]*MediaType{
+				"application/json": {Schema: body},
+			}}
+		}
+		op.Responses["400"] = &OpenAPIResponse{Description: http.StatusText(http.StatusBadRequest)}
+	}
+	// path params not declared by the request struct
+	for _, s := range strings.Split(route.Path, "/") {
+		if (strings.HasPrefix(s, ":") || strings.HasPrefix(s, "*")) && !params[s[1:]] {
+			op.Parameters = append(op.Parameters, &Parameter{Name: s[1:], In: "path", Required: true, Schema: &Schema{Type: "string"}})
+		}
+	}
+	if resp := doc.responseType(); resp != nil {
+		schema := b.schema(resp)
+		if doc.Envelope {
+			schema = &Schema{Type: "object", Properties: map[string]*Schema{
+				"code": {Type: "integer"},
+				"msg":  {Type: "string"},
+				"data": schema,
+			}}
+		}
+		op.Responses["200"].Content = map[string]*MediaType{
+			"application/json": {Schema: schema},
+		}
+	}
+	if doc.Envelope {
+		op.Responses["default"] = &OpenAPIResponse{Description: "error", Content: map[string]*MediaType{
+			"application/json": {Schema: b.schema(reflect.TypeOf(Response{}))},
+		}}
+	}
+	return op
+}
+
+// request split the request struct into parameters and body schema
+// uri tags are path params, header tags header params, form tags query params
+// the other fields are the json body, which GET and HEAD do not have,
+// so a field with both json and form tags is a body field when there is a body
+func (b *openAPIBuilder) request(t reflect.Type, method string, op *OpenAPIOp, params map[string]bool) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return b.schema(t)
+	}
+	body := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	hasBody := method != http.MethodGet && method != http.MethodHead
+	eachField(t, func(field reflect.StructField) {
+		schema, required := b.fieldSchema(field)
+		if name := tagName(field, "uri"); name != "" {
+			params[name] = true
+			op.Parameters = append(op.Parameters, &Parameter{Name: name, In: "path", Required: true, Schema: schema})
+			return
+		}
+		if name := tagName(field, "header"); name != "" {
+			op.Parameters = append(op.Parameters, &Parameter{Name: name, In: "header", Required: required, Schema: schema})
+			return
+		}
+		_, tagged := field.Tag.Lookup("json")
+		if name := tagName(field, "form"); name != "" && !(hasBody && tagged) {
+			op.Parameters = append(op.Parameters, &Parameter{Name: name, In: "query", Required: required, Schema: schema})
+			return
+		}
+		name := jsonName(field)
+		if name == "" || !hasBody {
+			return
+		}
+		body.Properties[name] = schema
+		if required {
+			body.Required = append(body.Required, name)
+		}
+	})
+	if len(body.Properties) == 0 {
+		return nil
+	}
+	return body
+}
+
+// schema build the schema of the type, structs are put into the components
+func (b *openAPIBuilder) schema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+	if t == reflect.TypeOf(json.RawMessage{}) {
+		return &Schema{}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		zero := 0.0
+		return &Schema{Type: "integer", Minimum: &zero}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: b.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: b.schema(t.Elem())}
+	case reflect.Struct:
+		return &Schema{Ref: "#/components/schemas/" + b.component(t)}
+	}
+	return &Schema{}
+}
+
+// component register the struct schema and return its name
+func (b *openAPIBuilder) component(t reflect.Type) string {
+	if name, ok := b.names[t]; ok {
+		return name
+	}
+	base := componentName(t)
+	name := base
+	for i := 2; b.schemas[name] != nil; i++ {
+		name = base + strconv.Itoa(i)
+	}
+	b.names[t] = name
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	b.schemas[name] = schema
+
+	eachField(t, func(field reflect.StructField) {
+		name := jsonName(field)
+		if name == "" {
+			return
+		}
+		fs, required := b.fieldSchema(field)
+		if required {
+			schema.Required = append(schema.Required, name)
+		}
+		schema.Properties[name] = fs
+	})
+	sort.Strings(schema.Required)
+	return name
+}
+
+// componentPackage package path inside a generic type name, demo: github.com/a/b.
+var componentPackage = regexp.MustCompile(`[\w.\-]*/`)
+
+// componentName name of the struct matching ^[a-zA-Z0-9.\-_]+$
+// demo: Page[github.com/a/rv.User] -> Page_rv.User, an anonymous struct -> Anonymous
+func componentName(t reflect.Type) string {
+	name := t.Name()
+	if name == "" {
+		return "Anonymous"
+	}
+	name = componentPackage.ReplaceAllString(name, "")
+	name = strings.NewReplacer("[", "_", "]", "", ",", "_", "*", "", " ", "").Replace(name)
+	return strings.Map(func(r rune) rune {
+		if r < 128 && (unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune(".-_", r)) {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// eachField call fn for every exported field, embedded structs are flattened
+func eachField(t reflect.Type, fn func(field reflect.StructField)) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous {
+			ft := field.Type
+			for ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct && field.Tag.Get("json") == "" {
+				eachField(ft, fn)
+				continue
+			}
+		}
+		if field.IsExported() {
+			fn(field)
+		}
+	}
+}
+
+// tagName name in the tag, empty if not set or "-"
+func tagName(field reflect.StructField, tag string) string {
+	name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
+// jsonName json name of the field, empty if the field is skipped
+func jsonName(field reflect.StructField) string {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return ""
+	}
+	if name, _, _ := strings.Cut(tag, ","); name != "" {
+		return name
+	}
+	return field.Name
+}
+
+// fieldSchema schema of the field with its binding constraints and whether it is required
+// the constraints of a component field go next to the reference in an allOf,
+// as the siblings of a $ref are ignored
+func (b *openAPIBuilder) fieldSchema(field reflect.StructField) (*Schema, bool) {
+	schema := b.schema(field.Type)
+	if schema.Ref == "" {
+		return schema, applyBinding(schema, field)
+	}
+	constraints := &Schema{}
+	required := applyBinding(constraints, field)
+	if reflect.DeepEqual(constraints, &Schema{}) {
+		return schema, required
+	}
+	constraints.AllOf = []*Schema{schema}
+	return constraints, required
+}
+
+// applyBinding put the binding tag constraints into the schema
+// and report whether the field is required
+func applyBinding(s *Schema, field reflect.StructField) bool {
+	tag := field.Tag.Get("binding")
+	if tag == "" {
+		return false
+	}
+	required := false
+	ft := field.Type
+	for ft.Kind() == reflect.Pointer {
+		ft = ft.Elem()
+	}
+	kind := ft.Kind()
+	for _, rule := range strings.Split(tag, ",") {
+		key, param, _ := strings.Cut(rule, "=")
+		if key == "dive" {
+			break
+		}
+		switch key {
+		case "required":
+			required = true
+		case "min", "max", "len", "gt", "gte", "lt", "lte":
+			applyLimit(s, kind, key, param)
+		case "oneof":
+			for _, v := range strings.Fields(param) {
+				if s.Type == "integer" || s.Type == "number" {
+					if n, err := strconv.ParseFloat(v, 64); err == nil {
+						s.Enum = append(s.Enum, n)
+						continue
+					}
+				}
+				s.Enum = append(s.Enum, v)
+			}
+		case "email", "uuid", "ipv4", "ipv6", "hostname":
+			s.Format = key
+		case "url", "uri":
+			s.Format = "uri"
+		case "alpha":
+			s.Pattern = `^[a-zA-Z]+$`
+		case "alphanum":
+			s.Pattern = `^[a-zA-Z0-9]+$`
+		case "numeric":
+			s.Pattern = `^[-+]?[0-9]+(?:\.[0-9]+)?$`
+		default:
+			if fn, ok := validate.Lookup(key); ok && !builtinTags[key] {
+				if pattern, ok := limiterPatterns[funcPointer(fn)]; ok {
+					s.Pattern = pattern
+				}
+				s.Validate = append(s.Validate, rule)
+			}
+		}
+	}
+	return required
+}
+
+// applyLimit put a min/max like rule into the schema
+func applyLimit(s *Schema, kind reflect.Kind, key, param string) {
+	n, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return
+	}
+	switch kind {
+	case reflect.String:
+		size := int(n)
+		if key == "gt" {
+			size++
+		}
+		if key == "lt" {
+			size--
+		}
+		switch key {
+		case "min", "gt", "gte":
+			s.MinLength = &size
+		case "max", "lt", "lte":
+			s.MaxLength = &size
+		case "len":
+			s.MinLength, s.MaxLength = &size, &size
+		}
+	case reflect.Slice, reflect.Array, reflect.Map:
+		size := int(n)
+		switch key {
+		case "min", "gte":
+			s.MinItems = &size
+		case "max", "lte":
+			s.MaxItems = &size
+		case "len":
+			s.MinItems, s.MaxItems = &size, &size
+		}
+	default:
+		switch key {
+		case "min", "gte":
+			s.Minimum = &n
+		case "gt":
+			s.Minimum, s.ExclusiveMinimum = &n, true
+		case "max", "lte":
+			s.Maximum = &n
+		case "lt":
+			s.Maximum, s.ExclusiveMaximum = &n, true
+		case "len":
+			s.Minimum, s.Maximum = &n, &n
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package ginx
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/miajio/gin-screw/pkg/validate"
+)
+
+type docAddress struct {
+	City string `json:"city" binding:"required"`
+}
+
+type docCreateUserReq struct {
+	Tenant  string      `header:"X-Tenant" binding:"required"`
+	Name    string      `json:"name" binding:"required,min=2,max=20"`
+	Role    string      `json:"role" binding:"oneof=admin user"`
+	Home    docAddress  `json:"home" binding:"required"`
+	Work    *docAddress `json:"work" binding:"omitempty,docaddress"`
+	Ignored string      `json:"-"`
+}
+
+type docGetUserReq struct {
+	ID   int64 `uri:"id" binding:"required"`
+	Full bool  `form:"full"`
+}
+
+type docUser struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestOpenAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	validate.Put("docaddress", func(validator.FieldLevel) bool { return true })
+	a := New(nil)
+	g := a.Engine().Group("/api")
+	a.DocRoute(g, http.MethodPost, "/users", HandleOperation[docCreateUserReq, docUser](Operation{Summary: "create user", Tags: []string{"user"}}),
+		Handle(func(ctx context.Context, req *docCreateUserReq) (*docUser, error) { return &docUser{}, nil }))
+	a.DocRoute(g, http.MethodGet, "/users/:id", HandleOperation[docGetUserReq, docUser](Operation{OperationID: "getUser"}),
+		Handle(func(ctx context.Context, req *docGetUserReq) (*docUser, error) { return &docUser{}, nil }))
+	g.GET("/files/*path", func(c *gin.Context) {})
+	a.ServeOpenAPI("/openapi.json", OpenAPIInfo{Title: "demo"})
+
+	w := httptest.NewRecorder()
+	a.Engine().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d", w.Code)
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path string // slash separated keys into the document
+		want any
+	}{
+		{"openapi", "3.0.3"},
+		{"info/title", "demo"},
+		{"paths/~1api~1users/post/summary", "create user"},
+		{"paths/~1api~1users/post/tags", []any{"user"}},
+		{"paths/~1api~1users/post/parameters", []any{map[string]any{"name": "X-Tenant", "in": "header", "required": true, "schema": map[string]any{"type": "string"}}}},
+		{"paths/~1api~1users/post/requestBody/content/application~1json/schema/required", []any{"name", "home"}},
+		{"paths/~1api~1users/post/requestBody/content/application~1json/schema/properties/name", map[string]any{"type": "string", "minLength": 2.0, "maxLength": 20.0}},
+		{"paths/~1api~1users/post/requestBody/content/application~1json/schema/properties/role/enum", []any{"admin", "user"}},
+		{"paths/~1api~1users/post/requestBody/content/application~1json/schema/properties/home", map[string]any{"$ref": "#/components/schemas/docAddress"}},
+		// the constraints of a reference go next to it in an allOf
+		{"paths/~1api~1users/post/requestBody/content/application~1json/schema/properties/work", map[string]any{
+			"allOf":      []any{map[string]any{"$ref": "#/components/schemas/docAddress"}},
+			"x-validate": []any{"docaddress"},
+		}},
+		{"paths/~1api~1users/post/responses/200/content/application~1json/schema/properties/data", map[string]any{"$ref": "#/components/schemas/docUser"}},
+		{"paths/~1api~1users/post/responses/400/description", "Bad Request"},
+		{"paths/~1api~1users~1{id}/get/operationId", "getUser"},
+		{"paths/~1api~1users~1{id}/get/parameters", []any{
+			map[string]any{"name": "id", "in": "path", "required": true, "schema": map[string]any{"type": "integer", "format": "int64"}},
+			map[string]any{"name": "full", "in": "query", "schema": map[string]any{"type": "boolean"}},
+		}},
+		{"paths/~1api~1users~1{id}/get/requestBody", nil},
+		// an undocumented route gets its path params only
+		{"paths/~1api~1files~1{path}/get/parameters", []any{map[string]any{"name": "path", "in": "path", "required": true, "schema": map[string]any{"type": "string"}}}},
+		{"paths/~1api~1files~1{path}/get/responses", map[string]any{"200": map[string]any{"description": "OK"}}},
+		{"paths/~1openapi.json", nil},
+		{"components/schemas/docAddress/required", []any{"city"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			var got any = doc
+			for _, key := range strings.Split(tt.path, "/") {
+				m, _ := got.(map[string]any)
+				got = m[strings.ReplaceAll(key, "~1", "/")]
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				out, _ := json.Marshal(got)
+				t.Fatalf("got %s, want %#v", out, tt.want)
+			}
+		})
+	}
+
+	w = httptest.NewRecorder()
+	a.Engine().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json?format=yaml", nil))
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "application/yaml") || !strings.Contains(w.Body.String(), "openapi: 3.0.3") {
+		t.Fatalf("yaml document %q: %s", w.Header().Get("Content-Type"), w.Body.String())
+	}
+}
@@ -0,0 +1,86 @@
+package ginx
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Operation route document
+type Operation struct {
+	OperationID string   // unique operation id
+	Summary     string   // short summary
+	Description string   // long description
+	Tags        []string // document tags
+	Request     any      // request struct, demo: LoginReq{}
+	Response    any      // response struct, demo: LoginResp{}
+	Envelope    bool     // the response is rendered in the Response envelope
+}
+
+// requestType request struct type
+func (op *Operation) requestType() reflect.Type {
+	return typeOf(op.Request)
+}
+
+// responseType response struct type
+func (op *Operation) responseType() reflect.Type {
+	return typeOf(op.Response)
+}
+
+// typeOf type of v without pointers, nil if v is nil
+func typeOf(v any) reflect.Type {
+	if v == nil {
+		return nil
+	}
+	if t, ok := v.(reflect.Type); ok {
+		return t
+	}
+	return reflect.TypeOf(v)
+}
+
+// HandleOperation the document of a Handle[Req, Resp] route, Req and Resp in the Response envelope
+// demo: ginx.DocRoute(g, http.MethodPost, "/login", ginx.HandleOperation[LoginReq, LoginResp](ginx.Operation{Summary: "login"}), ginx.Handle(login))
+func HandleOperation[Req, Resp any](op Operation) Operation {
+	op.Request = reflect.TypeOf((*Req)(nil)).Elem()
+	op.Response = reflect.TypeOf((*Resp)(nil)).Elem()
+	op.Envelope = true
+	return op
+}
+
+// Doc attach the document to the route, path is the absolute gin path, demo: /api/users/:id
+func (a *App) Doc(method, path string, op Operation) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.operations == nil {
+		a.operations = make(map[string]*Operation)
+	}
+	a.operations[strings.ToUpper(method)+" "+path] = &op
+}
+
+// DocRoute register the handlers on the group and attach the document to the route
+// demo: ginx.DocRoute(g, http.MethodGet, "/:id", ginx.Operation{Summary: "user", Response: User{}}, getUser)
+func (a *App) DocRoute(g *gin.RouterGroup, method, path string, op Operation, handlers ...gin.HandlerFunc) gin.IRoutes {
+	routes := g.Handle(method, path, handlers...)
+	a.Doc(method, joinPaths(g.BasePath(), path), op)
+	return routes
+}
+
+// Doc attach the document to the route of the singleton app
+func Doc(method, path string, op Operation) {
+	check()
+	this.Doc(method, path, op)
+}
+
+// DocRoute register a documented route of the singleton app
+func DocRoute(g *gin.RouterGroup, method, path string, op Operation, handlers ...gin.HandlerFunc) gin.IRoutes {
+	check()
+	return this.DocRoute(g, method, path, op, handlers...)
+}
+
+// operation document of the route, nil if the route is not documented
+func (a *App) operation(method, path string) *Operation {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.operations[method+" "+path]
+}
@@ -0,0 +1,301 @@
+package ginx
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// RateAlgorithm rate limiting algorithm
+type RateAlgorithm int
+
+const (
+	TokenBucket   RateAlgorithm = iota // Limit tokens refilled every Window, Burst tokens at most
+	SlidingWindow                      // at most Limit requests in any Window
+)
+
+// RatePolicy rate limiting policy
+type RatePolicy struct {
+	Name      string        // policy name, keys of different policies never collide
+	Algorithm RateAlgorithm // default TokenBucket
+	Limit     int           // requests allowed per window
+	Window    time.Duration // window size
+	Burst     int           // token bucket capacity, default Limit
+}
+
+// capacity max requests allowed at once
+func (p RatePolicy) capacity() int {
+	if p.Algorithm == TokenBucket && p.Burst > 0 {
+		return p.Burst
+	}
+	return p.Limit
+}
+
+// RateResult result of taking one request from the limiter
+type RateResult struct {
+	Allowed    bool          // the request is allowed
+	Limit      int           // max requests
+	Remaining  int           // requests left
+	Reset      time.Duration // time until the limit is fully available again
+	RetryAfter time.Duration // time to wait when not allowed
+}
+
+// RateLimitStore rate limiting state store
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, policy RatePolicy) (RateResult, error)
+}
+
+// RateKeyFunc rate limiting key of the request
+type RateKeyFunc func(c *gin.Context) string
+
+// KeyByIP limit by client ip
+func KeyByIP() RateKeyFunc {
+	return func(c *gin.Context) string {
+		return "ip:" + c.ClientIP()
+	}
+}
+
+// KeyByClaim limit by a pkg/jwt param of the authenticated request, demo: account
+func KeyByClaim(name string) RateKeyFunc {
+	return func(c *gin.Context) string {
+		if v := Claims(c)[name]; v != "" {
+			return "claim:" + v
+		}
+		return ""
+	}
+}
+
+// KeyByAPIKey limit by the api key header, default X-Api-Key
+func KeyByAPIKey(header string) RateKeyFunc {
+	if header == "" {
+		header = "X-Api-Key"
+	}
+	return func(c *gin.Context) string {
+		if v := c.GetHeader(header); v != "" {
+			return "apikey:" + v
+		}
+		return ""
+	}
+}
+
+// KeyByRoute limit by route template, shared by all clients
+func KeyByRoute() RateKeyFunc {
+	return func(c *gin.Context) string {
+		return "route:" + c.Request.Method + " " + c.FullPath()
+	}
+}
+
+// KeyJoin join several keys, demo: KeyJoin(KeyByRoute(), KeyByIP()) limits every client on every route
+func KeyJoin(fns ...RateKeyFunc) RateKeyFunc {
+	return func(c *gin.Context) string {
+		key := ""
+		for _, fn := range fns {
+			k := fn(c)
+			if k == "" {
+				return ""
+			}
+			key += "|" + k
+		}
+		return key
+	}
+}
+
+// RateLimitConfig rate limiting middleware config
+type RateLimitConfig struct {
+	Policy    RatePolicy                         // limiting policy
+	Key       RateKeyFunc                        // default KeyByIP, an empty key falls back to the client ip
+	Store     RateLimitStore                     // default a new MemoryRateStore
+	OnLimited func(c *gin.Context, r RateResult) // called when a request is rejected
+}
+
+var policySeq uint64
+
+// RateLimit rate limiting middleware
+// every group or route can use its own middleware with its own policy,
+// the RateLimit-* headers are set on every response and Retry-After on 429
+// store errors let the request pass
+func RateLimit(cfg RateLimitConfig) gin.HandlerFunc {
+	if cfg.Policy.Limit <= 0 || cfg.Policy.Window <= 0 {
+		panic("rate limit policy needs a positive Limit and Window")
+	}
+	if cfg.Key == nil {
+		cfg.Key = KeyByIP()
+	}
+	if cfg.Store == nil {
+		cfg.Store = NewMemoryRateStore()
+	}
+	if cfg.Policy.Name == "" {
+		cfg.Policy.Name = "policy" + strconv.FormatUint(atomic.AddUint64(&policySeq, 1), 10)
+	}
+	policyHeader := fmt.Sprintf("%d;w=%d", cfg.Policy.capacity(), int(math.Ceil(cfg.Policy.Window.Seconds())))
+
+	return func(c *gin.Context) {
+		key := cfg.Key(c)
+		if key == "" {
+			key = "ip:" + c.ClientIP()
+		}
+		r, err := cfg.Store.Take(c.Request.Context(), cfg.Policy.Name+":"+key, cfg.Policy)
+		if err != nil {
+			logger().Warn("rate limit store failed", zap.String("policy", cfg.Policy.Name), zap.Error(err))
+			c.Next()
+			return
+		}
+		c.Header("RateLimit-Policy", policyHeader)
+		c.Header("RateLimit-Limit", strconv.Itoa(r.Limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(r.Remaining))
+		c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(r.Reset)))
+		if r.Allowed {
+			c.Next()
+			return
+		}
+		c.Header("Retry-After", strconv.Itoa(ceilSeconds(r.RetryAfter)))
+		if cfg.OnLimited != nil {
+			cfg.OnLimited(c, r)
+		}
+		Fail(c, ErrTooManyRequests)
+	}
+}
+
+// ceilSeconds duration in whole seconds, rounded up
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// rateShards shard count of the memory store
+const rateShards = 64
+
+// MemoryRateStore in-memory sharded rate limiting store
+type MemoryRateStore struct {
+	shards [rateShards]rateShard
+	now    func() time.Time
+}
+
+type rateShard struct {
+	mu    sync.Mutex
+	items map[string]*rateState
+	takes int
+}
+
+// rateState state of one key
+type rateState struct {
+	tokens float64   // token bucket tokens
+	last   time.Time // token bucket last refill
+
+	start time.Time // sliding window current window start
+	prev  int       // sliding window previous window count
+	curr  int       // sliding window current window count
+
+	expire time.Time // the state can be dropped after
+}
+
+var _ RateLimitStore = (*MemoryRateStore)(nil)
+
+// NewMemoryRateStore create a memory store
+func NewMemoryRateStore() *MemoryRateStore {
+	s := &MemoryRateStore{now: time.Now}
+	for i := range s.shards {
+		s.shards[i].items = make(map[string]*rateState)
+	}
+	return s
+}
+
+// Take take one request
+func (s *MemoryRateStore) Take(_ context.Context, key string, p RatePolicy) (RateResult, error) {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	shard := &s.shards[h.Sum32()%rateShards]
+	now := s.now()
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	shard.takes++
+	if shard.takes%1024 == 0 {
+		shard.sweep(now)
+	}
+	st, ok := shard.items[key]
+	if !ok {
+		st = &rateState{tokens: float64(p.capacity()), last: now, start: now}
+		shard.items[key] = st
+	}
+	st.expire = now.Add(2 * p.Window)
+	if p.Algorithm == SlidingWindow {
+		return st.slidingWindow(now, p), nil
+	}
+	return st.tokenBucket(now, p), nil
+}
+
+// sweep drop the expired states
+func (shard *rateShard) sweep(now time.Time) {
+	for key, st := range shard.items {
+		if now.After(st.expire) {
+			delete(shard.items, key)
+		}
+	}
+}
+
+// tokenBucket refill then take one token
+func (st *rateState) tokenBucket(now time.Time, p RatePolicy) RateResult {
+	capacity := float64(p.capacity())
+	rate := float64(p.Limit) / p.Window.Seconds() // tokens per second
+	st.tokens = math.Min(capacity, st.tokens+now.Sub(st.last).Seconds()*rate)
+	st.last = now
+
+	r := RateResult{Limit: p.capacity()}
+	if st.tokens >= 1 {
+		st.tokens--
+		r.Allowed = true
+	} else {
+		r.RetryAfter = seconds((1 - st.tokens) / rate)
+	}
+	r.Remaining = int(st.tokens)
+	r.Reset = seconds((capacity - st.tokens) / rate)
+	return r
+}
+
+// slidingWindow weight the previous window count by its overlap with the sliding window
+func (st *rateState) slidingWindow(now time.Time, p RatePolicy) RateResult {
+	elapsed := now.Sub(st.start)
+	if elapsed >= p.Window {
+		windows := int(elapsed / p.Window)
+		if windows == 1 {
+			st.prev = st.curr
+		} else {
+			st.prev = 0
+		}
+		st.curr = 0
+		st.start = st.start.Add(time.Duration(windows) * p.Window)
+		elapsed = now.Sub(st.start)
+	}
+	weight := 1 - float64(elapsed)/float64(p.Window)
+	estimated := float64(st.prev)*weight + float64(st.curr)
+
+	r := RateResult{Limit: p.Limit, Reset: p.Window - elapsed}
+	if estimated+1 <= float64(p.Limit) {
+		st.curr++
+		r.Allowed = true
+		r.Remaining = int(float64(p.Limit) - estimated - 1)
+		return r
+	}
+	// wait until the previous window weighs little enough, or the next window
+	r.RetryAfter = p.Window - elapsed
+	if st.prev > 0 && st.curr+1 <= p.Limit {
+		need := 1 - float64(p.Limit-st.curr-1)/float64(st.prev)
+		if wait := time.Duration(need*float64(p.Window)) - elapsed; wait > 0 && wait < r.RetryAfter {
+			r.RetryAfter = wait
+		}
+	}
+	return r
+}
+
+// seconds float seconds to duration
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
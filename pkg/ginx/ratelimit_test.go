@@ -0,0 +1,106 @@
+package ginx
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMemoryRateStore(t *testing.T) {
+	type step struct {
+		at         time.Duration // since the first request
+		allowed    bool
+		remaining  int
+		retryAfter time.Duration
+	}
+	tests := []struct {
+		name   string
+		policy RatePolicy
+		steps  []step
+	}{
+		{"token bucket", RatePolicy{Algorithm: TokenBucket, Limit: 2, Window: time.Second}, []step{
+			{0, true, 1, 0},
+			{0, true, 0, 0},
+			{0, false, 0, 500 * time.Millisecond},
+			{500 * time.Millisecond, true, 0, 0},
+			{2 * time.Second, true, 1, 0},
+		}},
+		{"token bucket burst", RatePolicy{Algorithm: TokenBucket, Limit: 1, Window: time.Second, Burst: 3}, []step{
+			{0, true, 2, 0},
+			{0, true, 1, 0},
+			{0, true, 0, 0},
+			{0, false, 0, time.Second},
+		}},
+		{"sliding window", RatePolicy{Algorithm: SlidingWindow, Limit: 4, Window: 10 * time.Second}, []step{
+			{0, true, 3, 0},
+			{0, true, 2, 0},
+			{0, true, 1, 0},
+			{0, true, 0, 0},
+			{0, false, 0, 10 * time.Second},
+			// the previous window still weighs 4 at the start of the next one
+			{10 * time.Second, false, 0, 2500 * time.Millisecond},
+			// half of it is left at the middle
+			{15 * time.Second, true, 1, 0},
+			{15 * time.Second, true, 0, 0},
+			{15 * time.Second, false, 0, 2500 * time.Millisecond},
+			// two windows later nothing is left
+			{30 * time.Second, true, 3, 0},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			now := start
+			s := NewMemoryRateStore()
+			s.now = func() time.Time { return now }
+			for i, st := range tt.steps {
+				now = start.Add(st.at)
+				r, err := s.Take(context.Background(), "k", tt.policy)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if r.Allowed != st.allowed || r.Remaining != st.remaining || r.RetryAfter.Round(time.Millisecond) != st.retryAfter {
+					t.Fatalf("step %d: %+v, want allowed %v remaining %d retry after %s", i, r, st.allowed, st.remaining, st.retryAfter)
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimitPolicies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := NewMemoryRateStore()
+	policy := RatePolicy{Limit: 1, Window: time.Minute}
+	e := gin.New()
+	// unnamed policies on a shared store get their own names, so their keys never collide
+	e.GET("/a", RateLimit(RateLimitConfig{Policy: policy, Store: store}), func(c *gin.Context) {})
+	e.GET("/b", RateLimit(RateLimitConfig{Policy: policy, Store: store}), func(c *gin.Context) {})
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/a", http.StatusOK},
+		{"/b", http.StatusOK},
+		{"/a", http.StatusTooManyRequests},
+		{"/b", http.StatusTooManyRequests},
+	}
+	for i, tt := range tests {
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if w.Code != tt.status {
+			t.Fatalf("request %d %s: status %d, want %d", i, tt.path, w.Code, tt.status)
+		}
+		h := w.Header()
+		if h.Get("RateLimit-Policy") != "1;w=60" || h.Get("RateLimit-Limit") != "1" {
+			t.Fatalf("request %d: RateLimit headers %v", i, h)
+		}
+		if tt.status == http.StatusTooManyRequests && h.Get("Retry-After") != "60" {
+			t.Fatalf("request %d: Retry-After %q, want 60", i, h.Get("Retry-After"))
+		}
+	}
+}
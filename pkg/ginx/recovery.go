@@ -0,0 +1,130 @@
+package ginx
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// RecoveryConfig recovery middleware config
+type RecoveryConfig struct {
+	RedactHeaders []string                                          // headers hidden in the log, added to the defaults
+	RedactQuery   []string                                          // query keys hidden in the log, added to the defaults
+	OnPanic       func(c *gin.Context, recovered any, stack []byte) // alerting hook, called after logging
+}
+
+var (
+	defaultRedactHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "Proxy-Authorization", "X-Api-Key"}
+	defaultRedactQuery   = []string{"token", "access_token", "password", "secret", "sign", "key"}
+)
+
+// Recovery recover panics, log them through pkg/log and render ErrInternal
+// the log line carries the panic value, the stack, the route, the request id
+// and a request summary with the sensitive headers and query keys redacted
+func Recovery(cfg RecoveryConfig) gin.HandlerFunc {
+	headers := make(map[string]bool)
+	for _, h := range append(defaultRedactHeaders, cfg.RedactHeaders...) {
+		headers[http.CanonicalHeaderKey(h)] = true
+	}
+	query := make(map[string]bool)
+	for _, q := range append(defaultRedactQuery, cfg.RedactQuery...) {
+		query[strings.ToLower(q)] = true
+	}
+
+	return func(c *gin.Context) {
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			stack := debug.Stack()
+			err, ok := recovered.(error)
+			if !ok {
+				err = fmt.Errorf("%v", recovered)
+			}
+
+			fields := []zap.Field{
+				zap.Any("panic", recovered),
+				zap.String("method", c.Request.Method),
+				zap.String("route", c.FullPath()),
+				zap.String("requestId", RequestID(c)),
+				zap.String("traceId", traceID(c)),
+				zap.String("request", requestSummary(c, headers, query)),
+			}
+			if brokenPipe(err) {
+				// the connection is dead, nothing can be written
+				logger().Warn("connection broken", fields...)
+				_ = c.Error(err)
+				c.Abort()
+				return
+			}
+			logger().Error("panic recovered", append(fields, zap.ByteString("stack", stack))...)
+			if cfg.OnPanic != nil {
+				cfg.OnPanic(c, recovered, stack)
+			}
+			Fail(c, ErrInternal.Wrap(err))
+		}()
+		c.Next()
+	}
+}
+
+// brokenPipe check the panic is a broken connection
+func brokenPipe(err error) bool {
+	if errors.Is(err, http.ErrAbortHandler) {
+		return true
+	}
+	var ne *net.OpError
+	if !errors.As(err, &ne) {
+		return false
+	}
+	var se *os.SyscallError
+	if !errors.As(ne, &se) {
+		return false
+	}
+	msg := strings.ToLower(se.Error())
+	return strings.Contains(msg, "broken pipe") || strings.Contains(msg, "connection reset by peer")
+}
+
+// requestSummary one line request summary with the secrets redacted
+// demo: GET /users?token=*** ip=127.0.0.1 length=0 headers={Authorization:*** User-Agent:curl}
+func requestSummary(c *gin.Context, headers, query map[string]bool) string {
+	var b strings.Builder
+	b.WriteString(c.Request.Method)
+	b.WriteString(" ")
+	b.WriteString(c.Request.URL.Path)
+	if values := c.Request.URL.Query(); len(values) > 0 {
+		for key := range values {
+			if query[strings.ToLower(key)] {
+				values[key] = []string{"***"}
+			}
+		}
+		b.WriteString("?")
+		b.WriteString(strings.ReplaceAll(values.Encode(), "%2A%2A%2A", "***"))
+	}
+	fmt.Fprintf(&b, " ip=%s length=%d headers={", c.ClientIP(), c.Request.ContentLength)
+	keys := make([]string, 0, len(c.Request.Header))
+	for key := range c.Request.Header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for i, key := range keys {
+		if i > 0 {
+			b.WriteString(" ")
+		}
+		value := strings.Join(c.Request.Header[key], ",")
+		if headers[key] {
+			value = "***"
+		}
+		b.WriteString(key + ":" + value)
+	}
+	b.WriteString("}")
+	return b.String()
+}
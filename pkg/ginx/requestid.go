@@ -0,0 +1,207 @@
+package ginx
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/miajio/gin-screw/pkg/log"
+)
+
+// RequestIDHeader request id header
+const RequestIDHeader = "X-Request-ID"
+
+// TraceparentHeader W3C trace context header
+const TraceparentHeader = "traceparent"
+
+const (
+	requestIDKey = "ginx.requestId"
+	traceKey     = "ginx.trace"
+)
+
+type (
+	requestIDCtxKey struct{}
+	traceCtxKey     struct{}
+)
+
+// TraceContext W3C trace context
+type TraceContext struct {
+	TraceID  string // 32 hex
+	SpanID   string // 16 hex, span of this service
+	ParentID string // 16 hex, span of the caller, empty if the trace starts here
+	Flags    string // 2 hex, 01 is sampled
+}
+
+// String traceparent header value
+func (t TraceContext) String() string {
+	return "00-" + t.TraceID + "-" + t.SpanID + "-" + t.Flags
+}
+
+// Child trace context of an outgoing call
+func (t TraceContext) Child() TraceContext {
+	return TraceContext{TraceID: t.TraceID, SpanID: randomHex(8), ParentID: t.SpanID, Flags: t.Flags}
+}
+
+// ParseTraceparent parse the traceparent header
+// demo: 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+func ParseTraceparent(s string) (TraceContext, bool) {
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return TraceContext{}, false
+	}
+	if !isHex(parts[0]) || !isHex(parts[1]) || !isHex(parts[2]) || !isHex(parts[3]) ||
+		len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 ||
+		strings.Trim(parts[1], "0") == "" || strings.Trim(parts[2], "0") == "" {
+		return TraceContext{}, false
+	}
+	return TraceContext{TraceID: parts[1], SpanID: parts[2], Flags: parts[3]}, true
+}
+
+// NewTraceContext start a new sampled trace
+func NewTraceContext() TraceContext {
+	return TraceContext{TraceID: randomHex(16), SpanID: randomHex(8), Flags: "01"}
+}
+
+// TraceConfig request id and trace middleware config
+type TraceConfig struct {
+	IgnoreIncoming bool          // always generate the request id and start a new trace
+	Generator      func() string // request id generator, default 32 hex
+}
+
+// Trace accept or generate the X-Request-ID and the W3C traceparent
+// both are stored in the request context, with the log fields used by log.FromContext,
+// and the request id is written to the response header
+func Trace(cfg TraceConfig) gin.HandlerFunc {
+	if cfg.Generator == nil {
+		cfg.Generator = func() string { return randomHex(16) }
+	}
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if cfg.IgnoreIncoming || !validRequestID(id) {
+			id = cfg.Generator()
+		}
+		tc := NewTraceContext()
+		if parent, ok := ParseTraceparent(c.GetHeader(TraceparentHeader)); ok && !cfg.IgnoreIncoming {
+			tc = parent.Child()
+		}
+
+		c.Set(requestIDKey, id)
+		c.Set(traceKey, tc)
+		ctx := ContextWithTrace(ContextWithRequestID(c.Request.Context(), id), tc)
+		ctx = log.WithFields(ctx, "requestId", id, "traceId", tc.TraceID, "spanId", tc.SpanID)
+		c.Request = c.Request.WithContext(ctx)
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// RequestID get the request id of the request
+func RequestID(c *gin.Context) string {
+	if id := c.GetString(requestIDKey); id != "" {
+		return id
+	}
+	return c.GetHeader(RequestIDHeader)
+}
+
+// TraceOf get the trace context of the request
+func TraceOf(c *gin.Context) (TraceContext, bool) {
+	if v, ok := c.Get(traceKey); ok {
+		return v.(TraceContext), true
+	}
+	return TraceFrom(c.Request.Context())
+}
+
+// traceID trace id of the request, empty if there is no trace
+func traceID(c *gin.Context) string {
+	tc, _ := TraceOf(c)
+	return tc.TraceID
+}
+
+// ContextWithRequestID put the request id into the context
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDCtxKey{}, id)
+}
+
+// RequestIDFrom get the request id from the context
+func RequestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDCtxKey{}).(string)
+	return id
+}
+
+// ContextWithTrace put the trace context into the context
+func ContextWithTrace(ctx context.Context, tc TraceContext) context.Context {
+	return context.WithValue(ctx, traceCtxKey{}, tc)
+}
+
+// TraceFrom get the trace context from the context
+func TraceFrom(ctx context.Context) (TraceContext, bool) {
+	tc, ok := ctx.Value(traceCtxKey{}).(TraceContext)
+	return tc, ok
+}
+
+// Transport http.RoundTripper forwarding the request id and the traceparent
+// found in the outgoing request context
+type Transport struct {
+	Base http.RoundTripper // default http.DefaultTransport
+}
+
+// NewTransport create a Transport, demo: &http.Client{Transport: ginx.NewTransport(nil)}
+func NewTransport(base http.RoundTripper) *Transport {
+	return &Transport{Base: base}
+}
+
+// RoundTrip set the headers on a clone of the request
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	id := RequestIDFrom(req.Context())
+	tc, ok := TraceFrom(req.Context())
+	if id == "" && !ok {
+		return base.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	if id != "" && req.Header.Get(RequestIDHeader) == "" {
+		req.Header.Set(RequestIDHeader, id)
+	}
+	if ok && req.Header.Get(TraceparentHeader) == "" {
+		req.Header.Set(TraceparentHeader, tc.Child().String())
+	}
+	return base.RoundTrip(req)
+}
+
+// validRequestID accept short printable ids only
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// randomHex n random bytes as hex
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// isHex lower case hex
+func isHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !(s[i] >= '0' && s[i] <= '9' || s[i] >= 'a' && s[i] <= 'f') {
+			return false
+		}
+	}
+	return true
+}
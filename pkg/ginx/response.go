@@ -0,0 +1,223 @@
+package ginx
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// CodeOK business code of success
+const CodeOK = 0
+
+// Response unified response envelope
+type Response struct {
+	Code    int    `json:"code"`              // business code, 0 is success
+	Msg     string `json:"msg"`               // message
+	Data    any    `json:"data,omitempty"`    // response data
+	Details any    `json:"details,omitempty"` // error details
+}
+
+// Problem RFC 7807 problem details
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Code     int    `json:"code"`              // business code
+	Details  any    `json:"details,omitempty"` // error details
+}
+
+// FieldError validation error of a field
+type FieldError struct {
+	Field string `json:"field"`           // field namespace
+	Rule  string `json:"rule"`            // failed binding tag
+	Param string `json:"param,omitempty"` // tag param
+}
+
+// BizError business error
+type BizError struct {
+	Code    int    // business code
+	Status  int    // http status
+	Key     string // message key used for translation
+	Message string // default message
+	Details any    // error details
+	cause   error
+}
+
+var (
+	ErrBadRequest         = NewBizError(40000, http.StatusBadRequest, "bad_request", "bad request")
+	ErrUnauthorized       = NewBizError(40100, http.StatusUnauthorized, "unauthorized", "unauthorized")
+	ErrForbidden          = NewBizError(40300, http.StatusForbidden, "forbidden", "forbidden")
+	ErrNotFound           = NewBizError(40400, http.StatusNotFound, "not_found", "not found")
+	ErrConflict           = NewBizError(40900, http.StatusConflict, "conflict", "conflict")
+	ErrPayloadTooLarge    = NewBizError(41300, http.StatusRequestEntityTooLarge, "payload_too_large", "payload too large")
+	ErrTooManyRequests    = NewBizError(42900, http.StatusTooManyRequests, "too_many_requests", "too many requests")
+	ErrClientClosed       = NewBizError(49900, StatusClientClosedRequest, "client_closed", "client closed request")
+	ErrInternal           = NewBizError(50000, http.StatusInternalServerError, "internal", "internal server error")
+	ErrServiceUnavailable = NewBizError(50300, http.StatusServiceUnavailable, "service_unavailable", "service unavailable")
+	ErrTimeout            = NewBizError(50400, http.StatusGatewayTimeout, "timeout", "gateway timeout")
+)
+
+// NewBizError create a business error
+func NewBizError(code, status int, key, message string) *BizError {
+	return &BizError{Code: code, Status: status, Key: key, Message: message}
+}
+
+// Error implement error
+func (e *BizError) Error() string {
+	if e.cause != nil {
+		return e.Message + ": " + e.cause.Error()
+	}
+	return e.Message
+}
+
+// Unwrap the cause
+func (e *BizError) Unwrap() error {
+	return e.cause
+}
+
+// Is business errors with the same code are the same
+func (e *BizError) Is(target error) bool {
+	t, ok := target.(*BizError)
+	return ok && t.Code == e.Code
+}
+
+// StatusCode http status
+func (e *BizError) StatusCode() int {
+	return e.Status
+}
+
+// WithDetails copy the error with details
+func (e *BizError) WithDetails(details any) *BizError {
+	err := *e
+	err.Details = details
+	return &err
+}
+
+// WithMessage copy the error with another message
+func (e *BizError) WithMessage(message string) *BizError {
+	err := *e
+	err.Message = message
+	return &err
+}
+
+// Wrap copy the error with its cause, the cause is logged but not shown to the client
+func (e *BizError) Wrap(cause error) *BizError {
+	err := *e
+	err.cause = cause
+	return &err
+}
+
+// ErrorConfig error rendering config
+type ErrorConfig struct {
+	Problem     bool                                            // render RFC 7807 application/problem+json
+	ProblemType string                                          // problem type uri prefix, default about:blank
+	Translate   func(c *gin.Context, key string) (string, bool) // translate the message key
+	OnError     func(c *gin.Context, err error, biz *BizError)  // called before rendering, demo: log
+}
+
+const errorConfigKey = "ginx.errorConfig"
+
+// ErrorHandler render the last error attached by c.Error
+// when the handler did not write a response
+func ErrorHandler(cfg ErrorConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(errorConfigKey, &cfg)
+		c.Next()
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+		Fail(c, c.Errors.Last().Err)
+	}
+}
+
+// OK render data in the envelope
+func OK(c *gin.Context, data any) {
+	c.JSON(http.StatusOK, Response{Code: CodeOK, Msg: "ok", Data: data})
+}
+
+// Fail render the error and abort
+// errors that are not BizError are mapped with StatusOf,
+// the message of server errors is not shown to the client
+func Fail(c *gin.Context, err error) {
+	if err == nil {
+		err = ErrInternal
+	}
+	biz := ToBizError(err)
+	cfg := &ErrorConfig{}
+	if v, ok := c.Get(errorConfigKey); ok {
+		cfg = v.(*ErrorConfig)
+	}
+	if last := c.Errors.Last(); last == nil || last.Err != err {
+		_ = c.Error(err)
+	}
+	if cfg.OnError != nil {
+		cfg.OnError(c, err, biz)
+	}
+
+	msg := biz.Message
+	if cfg.Translate != nil && biz.Key != "" {
+		if v, ok := cfg.Translate(c, biz.Key); ok {
+			msg = v
+		}
+	}
+	if !cfg.Problem {
+		c.AbortWithStatusJSON(biz.Status, Response{Code: biz.Code, Msg: msg, Details: biz.Details})
+		return
+	}
+	problemType := "about:blank"
+	if cfg.ProblemType != "" {
+		problemType = cfg.ProblemType + biz.Key
+	}
+	c.Header("Content-Type", "application/problem+json; charset=utf-8")
+	c.AbortWithStatusJSON(biz.Status, Problem{
+		Type:     problemType,
+		Title:    http.StatusText(biz.Status),
+		Status:   biz.Status,
+		Detail:   msg,
+		Instance: c.Request.URL.Path,
+		Code:     biz.Code,
+		Details:  biz.Details,
+	})
+}
+
+// ToBizError map any error to a business error
+func ToBizError(err error) *BizError {
+	var biz *BizError
+	if errors.As(err, &biz) {
+		return biz
+	}
+	var ve validator.ValidationErrors
+	if errors.As(err, &ve) {
+		details := make([]FieldError, 0, len(ve))
+		for _, fe := range ve {
+			details = append(details, FieldError{Field: fe.Namespace(), Rule: fe.Tag(), Param: fe.Param()})
+		}
+		return ErrBadRequest.WithMessage("validation failed").WithDetails(details).Wrap(err)
+	}
+	var mbe *http.MaxBytesError
+	if errors.As(err, &mbe) {
+		return ErrPayloadTooLarge.Wrap(err)
+	}
+	var be *BindError
+	if errors.As(err, &be) {
+		return ErrBadRequest.WithMessage(be.Error()).Wrap(err)
+	}
+
+	status := StatusOf(err)
+	switch status {
+	case http.StatusGatewayTimeout:
+		return ErrTimeout.Wrap(err)
+	case StatusClientClosedRequest:
+		return ErrClientClosed.Wrap(err)
+	case http.StatusInternalServerError:
+		return ErrInternal.Wrap(err)
+	}
+	if status >= http.StatusInternalServerError {
+		return NewBizError(status*100, status, "", http.StatusText(status)).Wrap(err)
+	}
+	return NewBizError(status*100, status, "", err.Error()).Wrap(err)
+}
@@ -1,38 +1,31 @@
 package ginx
 
 import (
+	"context"
 	"sync"
 
 	"github.com/gin-gonic/gin"
 )
 
-// ginx
-type ginx struct {
-	engine  *gin.Engine // gin engine
-	routers []Router    // routers
-}
-
 var (
-	this *ginx
+	this *App
 	mu   sync.Mutex
 )
 
 // Init singleton init ginx
-func Init(engine *gin.Engine) *ginx {
+func Init(engine *gin.Engine) *App {
 	if this == nil {
 		mu.Lock()
 		defer mu.Unlock()
 		if this == nil {
-			this = &ginx{
-				engine:  engine,
-				routers: make([]Router, 0),
-			}
+			this = New(engine)
 		}
 	}
 	return this
 }
 
-func GetGinx() *ginx {
+// GetGinx get the singleton ginx app
+func GetGinx() *App {
 	check()
 	return this
 }
@@ -40,7 +33,7 @@ func GetGinx() *ginx {
 // Use use middleware
 func Use(middleware ...gin.HandlerFunc) {
 	check()
-	this.engine.Use(middleware...)
+	this.Use(middleware...)
 }
 
 // check ginx init
@@ -48,33 +41,46 @@ func check() {
 	if this == nil {
 		panic("ginx not init, please call Init()")
 	}
-	if this.engine == nil {
-		panic("gin engine is nil; please call Init()")
-	}
 }
 
 // AddRouter add router slice
 func AddRouters(routers ...Router) {
 	check()
-	mu.Lock()
-	defer mu.Unlock()
-	this.routers = append(this.routers, routers...)
+	this.AddRouters(routers...)
+}
+
+// AddGroupRouters add group router slice
+func AddGroupRouters(routers ...GroupRouter) {
+	check()
+	this.AddGroupRouters(routers...)
 }
 
 // RouterExecute execute router
 func RouterExecute() {
 	check()
-	mu.Lock()
-	defer mu.Unlock()
-	for _, router := range this.routers {
-		router.Execute(this.engine)
-	}
+	this.RouterExecute()
+}
+
+// RouterExecuteE execute router and return the dependency or route conflict error
+func RouterExecuteE() error {
+	check()
+	return this.RouterExecuteE()
+}
+
+// Start start the routers of the singleton app, see App.Start
+func Start(ctx context.Context) error {
+	check()
+	return this.Start(ctx)
+}
+
+// Stop stop the routers of the singleton app, see App.Stop
+func Stop(ctx context.Context) error {
+	check()
+	return this.Stop(ctx)
 }
 
 // Engine get gin engine
 func Engine() *gin.Engine {
 	check()
-	mu.Lock()
-	defer mu.Unlock()
-	return this.engine
+	return this.Engine()
 }
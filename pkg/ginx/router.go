@@ -6,3 +6,73 @@ import "github.com/gin-gonic/gin"
 type Router interface {
 	Execute(c *gin.Engine) // execute router
 }
+
+// GroupRouter router registered on a route group
+// ginx builds the gin.RouterGroup from prefix and middleware, then calls Routes
+type GroupRouter interface {
+	Prefix() string                // group prefix demo: /api/v1
+	Middleware() []gin.HandlerFunc // group middleware chain
+	Routes(g *gin.RouterGroup)     // register routes on the group
+}
+
+// SubRouters group router with nested group routers
+// the children prefix and middleware are relative to the parent group
+type SubRouters interface {
+	SubRouters() []GroupRouter
+}
+
+// Group inline group router
+type Group struct {
+	Path     string                   // group prefix
+	Handlers []gin.HandlerFunc        // group middleware chain
+	Register func(g *gin.RouterGroup) // register routes, can be nil
+	Children []GroupRouter            // nested group routers
+}
+
+var (
+	_ GroupRouter = (*Group)(nil)
+	_ SubRouters  = (*Group)(nil)
+)
+
+// Prefix group prefix
+func (g *Group) Prefix() string {
+	return g.Path
+}
+
+// Middleware group middleware chain
+func (g *Group) Middleware() []gin.HandlerFunc {
+	return g.Handlers
+}
+
+// Routes register routes on the group
+func (g *Group) Routes(rg *gin.RouterGroup) {
+	if g.Register != nil {
+		g.Register(rg)
+	}
+}
+
+// SubRouters nested group routers
+func (g *Group) SubRouters() []GroupRouter {
+	return g.Children
+}
+
+// groupRouter adapt GroupRouter to Router
+type groupRouter struct {
+	GroupRouter
+}
+
+// Execute build the group tree on the engine
+func (r groupRouter) Execute(c *gin.Engine) {
+	buildGroup(&c.RouterGroup, r.GroupRouter)
+}
+
+// buildGroup build the router group and its children
+func buildGroup(parent *gin.RouterGroup, router GroupRouter) {
+	g := parent.Group(router.Prefix(), router.Middleware()...)
+	router.Routes(g)
+	if sub, ok := router.(SubRouters); ok {
+		for _, child := range sub.SubRouters() {
+			buildGroup(g, child)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package ginx
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// routeEntry route with the router or controller owning it
+type routeEntry struct {
+	method string // http method
+	path   string // absolute path
+	owner  string // who registered the route
+}
+
+// String demo: GET /api/user (UserController.GetUser)
+func (r routeEntry) String() string {
+	return fmt.Sprintf("%s %s (%s)", r.method, r.path, r.owner)
+}
+
+// routeOverlap report whether two routes may be matched by the same request
+func routeOverlap(a, b routeEntry) bool {
+	if a.method != b.method {
+		return false
+	}
+	as, bs := strings.Split(strings.Trim(a.path, "/"), "/"), strings.Split(strings.Trim(b.path, "/"), "/")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if strings.HasPrefix(as[i], "*") || strings.HasPrefix(bs[i], "*") {
+			return true
+		}
+		if as[i] == bs[i] || strings.HasPrefix(as[i], ":") || strings.HasPrefix(bs[i], ":") {
+			continue
+		}
+		return false
+	}
+	return len(as) == len(bs)
+}
+
+// paramClash report whether the paths name the same param segment differently,
+// which gin rejects whatever the rest of the paths
+func paramClash(a, b string) bool {
+	as, bs := strings.Split(strings.Trim(a, "/"), "/"), strings.Split(strings.Trim(b, "/"), "/")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if as[i] == bs[i] {
+			continue
+		}
+		return strings.HasPrefix(as[i], ":") && strings.HasPrefix(bs[i], ":") ||
+			strings.HasPrefix(as[i], "*") || strings.HasPrefix(bs[i], "*")
+	}
+	return false
+}
+
+// RouteInfo route of the route table
+type RouteInfo struct {
+	Method  string `json:"method"`
+	Path    string `json:"path"`
+	Handler string `json:"handler"`         // handler func name
+	Owner   string `json:"owner,omitempty"` // router which registered the route, empty if registered on the engine directly
+}
+
+// executeRouter execute the router on the engine and return the routes it added
+// a gin panic is returned as error naming the known route the new one conflicts with
+func executeRouter(e *gin.Engine, r Router, known []routeEntry) (added []routeEntry, err error) {
+	owner := routerName(r)
+	owners := make(map[string]string)
+	if cr, ok := r.(*controllerRouter); ok {
+		for _, entry := range cr.entries() {
+			owners[entry.method+" "+entry.path] = entry.owner
+		}
+	}
+	before := make(map[string]bool)
+	for _, route := range e.Routes() {
+		before[route.Method+" "+route.Path] = true
+	}
+	defer func() {
+		for _, route := range e.Routes() {
+			key := route.Method + " " + route.Path
+			if before[key] {
+				continue
+			}
+			entry := routeEntry{method: route.Method, path: route.Path, owner: owners[key]}
+			if entry.owner == "" {
+				entry.owner = owner
+			}
+			added = append(added, entry)
+		}
+		if v := recover(); v != nil {
+			err = conflictError(owner, fmt.Sprint(v), append(known, added...))
+		}
+	}()
+	r.Execute(e)
+	return added, nil
+}
+
+// ginPanicPath path of the route in a gin registration panic
+var ginPanicPath = regexp.MustCompile(`(?:for|in|new) path '([^']*)'`)
+
+// conflictError explain the gin panic with the known route overlapping the new one
+func conflictError(owner, msg string, known []routeEntry) error {
+	m := ginPanicPath.FindStringSubmatch(msg)
+	if m == nil {
+		return fmt.Errorf("router %s: %s", owner, msg)
+	}
+	for _, k := range known {
+		if routeOverlap(routeEntry{method: k.method, path: m[1]}, k) {
+			return fmt.Errorf("router %s: route %s conflicts with %s: %s", owner, m[1], k, msg)
+		}
+	}
+	for _, k := range known {
+		if paramClash(m[1], k.path) {
+			return fmt.Errorf("router %s: route %s conflicts with %s: %s", owner, m[1], k, msg)
+		}
+	}
+	return fmt.Errorf("router %s: route %s: %s", owner, m[1], msg)
+}
+
+// knownEntries routes already on the engine with their owner, a.mu is held
+func (a *App) knownEntries() []routeEntry {
+	owners := make(map[string]string, len(a.routeOwners))
+	for _, entry := range a.routeOwners {
+		owners[entry.method+" "+entry.path] = entry.owner
+	}
+	entries := make([]routeEntry, 0)
+	for _, route := range a.engine.Routes() {
+		owner := owners[route.Method+" "+route.Path]
+		if owner == "" {
+			owner = "engine"
+		}
+		entries = append(entries, routeEntry{method: route.Method, path: route.Path, owner: owner})
+	}
+	return entries
+}
+
+// Routes the route table of the engine with the router owning each route
+func (a *App) Routes() []RouteInfo {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	owners := make(map[string]string, len(a.routeOwners))
+	for _, entry := range a.routeOwners {
+		owners[entry.method+" "+entry.path] = entry.owner
+	}
+	routes := a.engine.Routes()
+	result := make([]RouteInfo, 0, len(routes))
+	for _, route := range routes {
+		result = append(result, RouteInfo{
+			Method:  route.Method,
+			Path:    route.Path,
+			Handler: route.Handler,
+			Owner:   owners[route.Method+" "+route.Path],
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Path != result[j].Path {
+			return result[i].Path < result[j].Path
+		}
+		return result[i].Method < result[j].Method
+	})
+	return result
+}
+
+// ServeRoutes serve the route table as json on path, demo: /debug/routes
+// the table is read on every request, so routes added later are listed too
+func (a *App) ServeRoutes(path string) {
+	a.Engine().GET(path, func(c *gin.Context) {
+		c.JSON(http.StatusOK, a.Routes())
+	})
+}
+
+// Routes the route table of the singleton app
+func Routes() []RouteInfo {
+	check()
+	return this.Routes()
+}
+
+// ServeRoutes serve the route table of the singleton app
+func ServeRoutes(path string) {
+	check()
+	this.ServeRoutes(path)
+}
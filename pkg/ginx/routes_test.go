@@ -0,0 +1,71 @@
+package ginx
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRouterExecuteConflict(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler := func(c *gin.Context) {}
+	tests := []struct {
+		name   string
+		groups []*Group
+		err    string // part of the error, empty for none
+	}{
+		{"distinct routes", []*Group{
+			{Path: "/users", Register: func(g *gin.RouterGroup) { g.GET("/:id", handler) }},
+			{Path: "/orders", Register: func(g *gin.RouterGroup) { g.GET("/:id", handler) }},
+		}, ""},
+		{"same route", []*Group{
+			{Path: "/users", Register: func(g *gin.RouterGroup) { g.GET("/:id", handler) }},
+			{Path: "/users", Register: func(g *gin.RouterGroup) { g.GET("/:id", handler) }},
+		}, "conflicts with GET /users/:id (Group)"},
+		{"param name", []*Group{
+			{Path: "/users", Register: func(g *gin.RouterGroup) { g.GET("/:id", handler) }},
+			{Path: "/users", Register: func(g *gin.RouterGroup) { g.GET("/:name/posts", handler) }},
+		}, "route /users/:name/posts conflicts with GET /users/:id (Group)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := New(nil)
+			for _, g := range tt.groups {
+				a.AddGroupRouters(g)
+			}
+			err := a.RouterExecuteE()
+			if tt.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestRoutesOwners(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	a := New(nil)
+	a.Engine().GET("/ping", func(c *gin.Context) {})
+	a.AddGroupRouters(&Group{Path: "/users", Register: func(g *gin.RouterGroup) {
+		g.GET("/:id", func(c *gin.Context) {})
+	}})
+	a.RouterExecute()
+
+	owners := make(map[string]string)
+	for _, route := range a.Routes() {
+		owners[route.Method+" "+route.Path] = route.Owner
+	}
+	want := map[string]string{http.MethodGet + " /ping": "", http.MethodGet + " /users/:id": "Group"}
+	for route, owner := range want {
+		if got, ok := owners[route]; !ok || got != owner {
+			t.Fatalf("owner of %s %q, want %q", route, got, owner)
+		}
+	}
+}
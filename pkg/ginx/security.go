@@ -0,0 +1,299 @@
+package ginx
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SecurityConfig security response headers
+// an empty field uses the default, "-" removes the header
+type SecurityConfig struct {
+	ContentSecurityPolicy string        // default default-src 'self'; object-src 'none'; base-uri 'self'; frame-ancestors 'none'
+	FrameOptions          string        // X-Frame-Options, default DENY
+	ReferrerPolicy        string        // default strict-origin-when-cross-origin
+	PermissionsPolicy     string        // default camera=(), microphone=(), geolocation=()
+	HSTSMaxAge            time.Duration // Strict-Transport-Security max-age on https requests, default 180 days, negative disable
+	HSTSIncludeSubdomains bool          // add includeSubDomains
+	HSTSPreload           bool          // add preload
+}
+
+// SecurityHeaders set the security headers, X-Content-Type-Options: nosniff is always set
+// routes override them with SecurityOverride
+func SecurityHeaders(cfg SecurityConfig) gin.HandlerFunc {
+	cfg = cfg.withDefaults()
+	return func(c *gin.Context) {
+		cfg.apply(c)
+		c.Next()
+	}
+}
+
+// SecurityOverride override the security headers of a route or group
+// only the non-empty fields are applied, demo: SecurityOverride(SecurityConfig{FrameOptions: "SAMEORIGIN"})
+func SecurityOverride(cfg SecurityConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg.apply(c)
+		c.Next()
+	}
+}
+
+// withDefaults fill the empty fields
+func (cfg SecurityConfig) withDefaults() SecurityConfig {
+	if cfg.ContentSecurityPolicy == "" {
+		cfg.ContentSecurityPolicy = "default-src 'self'; object-src 'none'; base-uri 'self'; frame-ancestors 'none'"
+	}
+	if cfg.FrameOptions == "" {
+		cfg.FrameOptions = "DENY"
+	}
+	if cfg.ReferrerPolicy == "" {
+		cfg.ReferrerPolicy = "strict-origin-when-cross-origin"
+	}
+	if cfg.PermissionsPolicy == "" {
+		cfg.PermissionsPolicy = "camera=(), microphone=(), geolocation=()"
+	}
+	if cfg.HSTSMaxAge == 0 {
+		cfg.HSTSMaxAge = 180 * 24 * time.Hour
+	}
+	return cfg
+}
+
+// apply set the non-empty headers
+func (cfg SecurityConfig) apply(c *gin.Context) {
+	h := c.Writer.Header()
+	h.Set("X-Content-Type-Options", "nosniff")
+	setHeader(h, "Content-Security-Policy", cfg.ContentSecurityPolicy)
+	setHeader(h, "X-Frame-Options", cfg.FrameOptions)
+	setHeader(h, "Referrer-Policy", cfg.ReferrerPolicy)
+	setHeader(h, "Permissions-Policy", cfg.PermissionsPolicy)
+
+	switch {
+	case cfg.HSTSMaxAge < 0:
+		h.Del("Strict-Transport-Security")
+	case cfg.HSTSMaxAge > 0 && isHTTPS(c.Request):
+		v := "max-age=" + strconv.Itoa(int(cfg.HSTSMaxAge.Seconds()))
+		if cfg.HSTSIncludeSubdomains {
+			v += "; includeSubDomains"
+		}
+		if cfg.HSTSPreload {
+			v += "; preload"
+		}
+		h.Set("Strict-Transport-Security", v)
+	}
+}
+
+// setHeader set the header, "-" removes it and empty keeps it
+func setHeader(h http.Header, key, value string) {
+	switch value {
+	case "":
+	case "-":
+		h.Del(key)
+	default:
+		h.Set(key, value)
+	}
+}
+
+// forwardedProxies networks of the proxies whose X-Forwarded-Proto is trusted
+var forwardedProxies atomic.Pointer[[]*net.IPNet]
+
+func init() {
+	if err := SetForwardedProxies("127.0.0.0/8", "::1/128", "10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7"); err != nil {
+		panic(err)
+	}
+}
+
+// SetForwardedProxies set the proxies whose X-Forwarded-Proto header is trusted, as ips or cidrs
+// default the loopback and private networks, no argument trusts none
+// the header of the other peers is ignored, so a client can not pass for https
+func SetForwardedProxies(proxies ...string) error {
+	nets := make([]*net.IPNet, 0, len(proxies))
+	for _, p := range proxies {
+		if !strings.Contains(p, "/") {
+			ip := net.ParseIP(p)
+			if ip == nil {
+				return fmt.Errorf("forwarded proxy %q is not an ip or a cidr", p)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(p)
+		if err != nil {
+			return fmt.Errorf("forwarded proxy %q: %w", p, err)
+		}
+		nets = append(nets, n)
+	}
+	forwardedProxies.Store(&nets)
+	return nil
+}
+
+// forwardedProxy report whether the peer of the request is a trusted proxy
+func forwardedProxy(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, n := range *forwardedProxies.Load() {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// isHTTPS report whether the request came over https, directly or through a trusted proxy
+func isHTTPS(r *http.Request) bool {
+	return r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https") && forwardedProxy(r)
+}
+
+// CSRFConfig double-submit cookie CSRF protection config
+type CSRFConfig struct {
+	CookieName string                  // default csrf_token
+	HeaderName string                  // default X-CSRF-Token
+	FormField  string                  // form field checked when the header is missing, default csrf_token
+	Path       string                  // cookie path, default /
+	Domain     string                  // cookie domain
+	MaxAge     time.Duration           // cookie lifetime, default 12h
+	Secure     bool                    // https only cookie
+	SameSite   http.SameSite           // default Lax
+	Origins    []string                // other origins allowed to send unsafe requests, demo: https://admin.a.com
+	Skip       func(*gin.Context) bool // skip the check, demo: requests authenticated by a bearer token
+}
+
+const csrfKey = "ginx.csrf"
+
+// CSRF double-submit cookie protection for cookie authenticated routes
+// the token cookie is readable by scripts, which send it back in the header or the form field;
+// GET, HEAD, OPTIONS and TRACE are not checked, other requests without a matching token get 403,
+// so do the ones with an Origin header that is neither the origin of the request nor in Origins
+func CSRF(cfg CSRFConfig) gin.HandlerFunc {
+	if cfg.CookieName == "" {
+		cfg.CookieName = "csrf_token"
+	}
+	if cfg.HeaderName == "" {
+		cfg.HeaderName = "X-CSRF-Token"
+	}
+	if cfg.FormField == "" {
+		cfg.FormField = "csrf_token"
+	}
+	if cfg.Path == "" {
+		cfg.Path = "/"
+	}
+	if cfg.MaxAge == 0 {
+		cfg.MaxAge = 12 * time.Hour
+	}
+	if cfg.SameSite == 0 {
+		cfg.SameSite = http.SameSiteLaxMode
+	}
+	origins := make(map[string]bool, len(cfg.Origins))
+	for _, o := range cfg.Origins {
+		origins[strings.ToLower(strings.TrimRight(o, "/"))] = true
+	}
+
+	return func(c *gin.Context) {
+		if cfg.Skip != nil && cfg.Skip(c) {
+			c.Next()
+			return
+		}
+		token, _ := c.Cookie(cfg.CookieName)
+		if len(token) != 64 || !isHex(token) {
+			token = ""
+		}
+
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		default:
+			sent := c.GetHeader(cfg.HeaderName)
+			if sent == "" {
+				sent = c.PostForm(cfg.FormField)
+			}
+			if token == "" || subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+				Fail(c, ErrForbidden.WithMessage("csrf token invalid"))
+				return
+			}
+			if origin := c.GetHeader("Origin"); origin != "" && !origins[strings.ToLower(origin)] && !sameOrigin(c.Request, origin) {
+				Fail(c, ErrForbidden.WithMessage("csrf origin invalid"))
+				return
+			}
+		}
+
+		if token == "" {
+			token = randomHex(32)
+			c.SetSameSite(cfg.SameSite)
+			c.SetCookie(cfg.CookieName, token, int(cfg.MaxAge.Seconds()), cfg.Path, cfg.Domain, cfg.Secure, false)
+		}
+		c.Set(csrfKey, token)
+		c.Next()
+	}
+}
+
+// CSRFToken token of the request, for templates rendering forms
+func CSRFToken(c *gin.Context) string {
+	return c.GetString(csrfKey)
+}
+
+// BodyLimit reject request bodies over max bytes with 413
+// a known Content-Length is rejected before the handler runs,
+// a chunked body fails when the handler reads past the limit
+func BodyLimit(max int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.ContentLength > max {
+			Fail(c, ErrPayloadTooLarge)
+			return
+		}
+		if c.Request.Body != nil {
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, max)
+		}
+		c.Next()
+	}
+}
+
+// HardenConfig hardened defaults bundle config
+type HardenConfig struct {
+	Security  SecurityConfig // security headers
+	CSRF      *CSRFConfig    // CSRF protection, nil disable
+	BodyLimit int64          // max request body bytes, default 8MB, negative disable
+}
+
+// HardenHandlers the middleware of the bundle, for a route group
+func HardenHandlers(cfg HardenConfig) []gin.HandlerFunc {
+	handlers := []gin.HandlerFunc{SecurityHeaders(cfg.Security)}
+	if cfg.BodyLimit == 0 {
+		cfg.BodyLimit = 8 << 20
+	}
+	if cfg.BodyLimit > 0 {
+		handlers = append(handlers, BodyLimit(cfg.BodyLimit))
+	}
+	if cfg.CSRF != nil {
+		handlers = append(handlers, CSRF(*cfg.CSRF))
+	}
+	return handlers
+}
+
+// Harden use the security headers, the body size limit and the optional CSRF protection
+func (a *App) Harden(cfg ...HardenConfig) {
+	var c HardenConfig
+	if len(cfg) > 0 {
+		c = cfg[0]
+	}
+	a.Use(HardenHandlers(c)...)
+}
+
+// Harden harden the singleton app
+func Harden(cfg ...HardenConfig) {
+	check()
+	this.Harden(cfg...)
+}
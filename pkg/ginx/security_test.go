@@ -0,0 +1,143 @@
+package ginx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCSRF(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(CSRF(CSRFConfig{Skip: func(c *gin.Context) bool {
+		return strings.HasPrefix(c.GetHeader("Authorization"), "Bearer ")
+	}}))
+	e.Any("/form", func(c *gin.Context) {
+		c.String(http.StatusOK, CSRFToken(c))
+	})
+
+	token := strings.Repeat("ab", 32)
+	other := strings.Repeat("cd", 32)
+	tests := []struct {
+		name   string
+		method string
+		cookie string
+		header string
+		form   string
+		bearer bool
+		status int
+		issued bool // a new token cookie is set
+	}{
+		{"get issues a token", http.MethodGet, "", "", "", false, http.StatusOK, true},
+		{"get keeps the token", http.MethodGet, token, "", "", false, http.StatusOK, false},
+		{"header matches", http.MethodPost, token, token, "", false, http.StatusOK, false},
+		{"form field matches", http.MethodPost, token, "", token, false, http.StatusOK, false},
+		{"no cookie", http.MethodPost, "", token, "", false, http.StatusForbidden, false},
+		{"no token sent", http.MethodPost, token, "", "", false, http.StatusForbidden, false},
+		{"token mismatch", http.MethodDelete, token, other, "", false, http.StatusForbidden, false},
+		{"malformed cookie", http.MethodPost, "short", "short", "", false, http.StatusForbidden, false},
+		{"skipped for bearer tokens", http.MethodPost, "", "", "", true, http.StatusOK, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body *strings.Reader
+			if tt.form != "" {
+				body = strings.NewReader(url.Values{"csrf_token": {tt.form}}.Encode())
+			} else {
+				body = strings.NewReader("")
+			}
+			req := httptest.NewRequest(tt.method, "/form", body)
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: "csrf_token", Value: tt.cookie})
+			}
+			if tt.header != "" {
+				req.Header.Set("X-CSRF-Token", tt.header)
+			}
+			if tt.bearer {
+				req.Header.Set("Authorization", "Bearer x")
+			}
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, req)
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+			if issued := strings.Contains(w.Header().Get("Set-Cookie"), "csrf_token="); issued != tt.issued {
+				t.Fatalf("token cookie issued %v, want %v", issued, tt.issued)
+			}
+			if tt.status == http.StatusOK && !tt.bearer && tt.cookie != "" && w.Body.String() != tt.cookie {
+				t.Fatalf("CSRFToken %q, want %q", w.Body.String(), tt.cookie)
+			}
+		})
+	}
+}
+
+func TestCSRFOrigin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(CSRF(CSRFConfig{Origins: []string{"https://admin.example.com"}}))
+	e.POST("/form", func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+
+	token := strings.Repeat("ab", 32)
+	tests := []struct {
+		name   string
+		origin string
+		proto  string // X-Forwarded-Proto
+		peer   string // RemoteAddr
+		status int
+	}{
+		{"no origin", "", "", "203.0.113.5:1234", http.StatusOK},
+		{"same origin", "http://api.local", "", "203.0.113.5:1234", http.StatusOK},
+		{"other origin", "https://evil.com", "", "203.0.113.5:1234", http.StatusForbidden},
+		{"allowed origin", "https://admin.example.com", "", "203.0.113.5:1234", http.StatusOK},
+		{"https behind a proxy", "https://api.local", "https", "10.0.0.7:1234", http.StatusOK},
+		{"http behind a proxy", "http://api.local", "https", "10.0.0.7:1234", http.StatusForbidden},
+		{"spoofed proto", "https://api.local", "https", "203.0.113.5:1234", http.StatusForbidden},
+		{"spoofed proto keeps http", "http://api.local", "https", "203.0.113.5:1234", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "http://api.local/form", nil)
+			req.RemoteAddr = tt.peer
+			req.AddCookie(&http.Cookie{Name: "csrf_token", Value: token})
+			req.Header.Set("X-CSRF-Token", token)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.proto != "" {
+				req.Header.Set("X-Forwarded-Proto", tt.proto)
+			}
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, req)
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestSecurityHeadersForwardedProto(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(SecurityHeaders(SecurityConfig{}))
+	e.GET("/", func(c *gin.Context) {})
+	for peer, hsts := range map[string]bool{"127.0.0.1:1234": true, "203.0.113.5:1234": false} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = peer
+		req.Header.Set("X-Forwarded-Proto", "https")
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		if got := w.Header().Get("Strict-Transport-Security") != ""; got != hsts {
+			t.Fatalf("peer %s: HSTS %v, want %v", peer, got, hsts)
+		}
+	}
+	if err := SetForwardedProxies("not-an-ip"); err == nil {
+		t.Fatal("invalid proxy accepted")
+	}
+}
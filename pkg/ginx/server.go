@@ -0,0 +1,167 @@
+package ginx
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/miajio/gin-screw/pkg/log"
+)
+
+// ServerConfig http server config
+// zero values use the defaults below
+type ServerConfig struct {
+	Addr              string        // listen address, default :8080
+	ReadTimeout       time.Duration // default 15s
+	ReadHeaderTimeout time.Duration // default 5s
+	WriteTimeout      time.Duration // default 30s
+	IdleTimeout       time.Duration // default 60s
+	ShutdownTimeout   time.Duration // max time to drain in-flight requests, default 15s
+	ShutdownDelay     time.Duration // time readiness fails before draining starts, 0 disable
+	Signals           []os.Signal   // shutdown signals, default SIGINT and SIGTERM
+}
+
+// ShutdownHook called after the server stopped accepting requests
+type ShutdownHook func(ctx context.Context) error
+
+// withDefaults fill the zero values
+func (cfg ServerConfig) withDefaults() ServerConfig {
+	if cfg.Addr == "" {
+		cfg.Addr = ":8080"
+	}
+	if cfg.ReadTimeout == 0 {
+		cfg.ReadTimeout = 15 * time.Second
+	}
+	if cfg.ReadHeaderTimeout == 0 {
+		cfg.ReadHeaderTimeout = 5 * time.Second
+	}
+	if cfg.WriteTimeout == 0 {
+		cfg.WriteTimeout = 30 * time.Second
+	}
+	if cfg.IdleTimeout == 0 {
+		cfg.IdleTimeout = 60 * time.Second
+	}
+	if cfg.ShutdownTimeout == 0 {
+		cfg.ShutdownTimeout = 15 * time.Second
+	}
+	if len(cfg.Signals) == 0 {
+		cfg.Signals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+	return cfg
+}
+
+// OnShutdown register shutdown hook
+// hooks run in reverse order of registration
+func (a *App) OnShutdown(hooks ...ShutdownHook) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.shutdownHooks = append(a.shutdownHooks, hooks...)
+}
+
+// OnDrain register functions called when the server starts draining,
+// before it waits for the in-flight requests, to end the long lived streams
+func (a *App) OnDrain(fns ...func()) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.drainHooks = append(a.drainHooks, fns...)
+}
+
+// Run start the routers and serve http until ctx is done or a shutdown signal arrives
+// then drain in-flight requests, stop the routers, run the shutdown hooks and flush the logger
+func (a *App) Run(ctx context.Context, cfg ServerConfig) error {
+	cfg = cfg.withDefaults()
+	if err := a.Start(ctx); err != nil {
+		return err
+	}
+	srv := &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           a.Engine(),
+		ReadTimeout:       cfg.ReadTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, cfg.Signals...)
+	defer stop()
+
+	errCh := make(chan error, 1)
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errCh <- err
+		}
+		close(errCh)
+	}()
+
+	select {
+	case err := <-errCh:
+		if err != nil {
+			return errors.Join(err, a.Stop(context.Background()))
+		}
+	case <-ctx.Done():
+	}
+
+	// readiness fails from now on, give the load balancers time to notice
+	a.shuttingDown.Store(true)
+	if cfg.ShutdownDelay > 0 {
+		time.Sleep(cfg.ShutdownDelay)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	return a.shutdown(shutdownCtx, srv)
+}
+
+// shutdown drain the server, stop the routers, run the hooks and flush the logger
+func (a *App) shutdown(ctx context.Context, srv *http.Server) error {
+	a.shuttingDown.Store(true)
+	a.mu.Lock()
+	drains := append([]func(){}, a.drainHooks...)
+	a.mu.Unlock()
+	for _, fn := range drains {
+		fn()
+	}
+
+	errs := make([]error, 0)
+	if err := srv.Shutdown(ctx); err != nil {
+		errs = append(errs, err)
+	}
+	if err := a.Stop(ctx); err != nil {
+		errs = append(errs, err)
+	}
+
+	a.mu.Lock()
+	hooks := append([]ShutdownHook(nil), a.shutdownHooks...)
+	a.mu.Unlock()
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if err := hooks[i](ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	// stdout can not always be synced, so the error is ignored
+	_ = log.Sync()
+	return errors.Join(errs...)
+}
+
+// OnShutdown register shutdown hook on the singleton app
+func OnShutdown(hooks ...ShutdownHook) {
+	check()
+	this.OnShutdown(hooks...)
+}
+
+// OnDrain register drain functions on the singleton app
+func OnDrain(fns ...func()) {
+	check()
+	this.OnDrain(fns...)
+}
+
+// Run run the singleton app, see App.Run
+func Run(ctx context.Context, cfg ServerConfig) error {
+	check()
+	return this.Run(ctx, cfg)
+}
@@ -0,0 +1,143 @@
+package ginx
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// serverJournal lifecycle events recorded from the server goroutines
+type serverJournal struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (j *serverJournal) add(event string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.events = append(j.events, event)
+}
+
+func (j *serverJournal) list() []string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return append([]string(nil), j.events...)
+}
+
+// serverRouter router recording its start and stop
+type serverRouter struct {
+	journal *serverJournal
+}
+
+func (r *serverRouter) Name() string                  { return "db" }
+func (r *serverRouter) Execute(*gin.Engine)           {}
+func (r *serverRouter) OnStart(context.Context) error { r.journal.add("start"); return nil }
+func (r *serverRouter) OnStop(context.Context) error  { r.journal.add("stop"); return nil }
+
+// freeAddr a local address nobody listens on
+func freeAddr(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().String()
+}
+
+func TestRunShutdown(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	journal := &serverJournal{}
+	a := New(nil)
+	a.AddRouters(&serverRouter{journal: journal})
+
+	entered, release := make(chan struct{}), make(chan struct{})
+	a.Engine().GET("/slow", func(c *gin.Context) {
+		close(entered)
+		<-release
+		journal.add("request done")
+		c.String(http.StatusOK, "done")
+	})
+	a.OnDrain(func() {
+		if !a.ShuttingDown() {
+			t.Error("drain before readiness failed")
+		}
+		journal.add("drain")
+		// the in-flight request finishes while the server drains
+		close(release)
+	})
+	a.OnShutdown(
+		func(context.Context) error { journal.add("hook 1"); return nil },
+		func(context.Context) error { journal.add("hook 2"); return errors.New("hook 2 failed") },
+	)
+
+	addr := freeAddr(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+	go func() { result <- a.Run(ctx, ServerConfig{Addr: addr, ShutdownDelay: 20 * time.Millisecond}) }()
+
+	response := make(chan error, 1)
+	go func() {
+		for i := 0; ; i++ {
+			resp, err := http.Get("http://" + addr + "/slow")
+			if err != nil && i < 100 {
+				// not listening yet
+				time.Sleep(10 * time.Millisecond)
+				continue
+			}
+			if err == nil {
+				resp.Body.Close()
+				if resp.StatusCode != http.StatusOK {
+					err = errors.New(resp.Status)
+				}
+			}
+			response <- err
+			return
+		}
+	}()
+
+	<-entered
+	if a.ShuttingDown() {
+		t.Fatal("shutting down before the cancel")
+	}
+	cancel()
+	err := <-result
+	if err == nil || err.Error() != "hook 2 failed" {
+		t.Fatalf("run error %v, want the hook error", err)
+	}
+	if err := <-response; err != nil {
+		t.Fatalf("in-flight request: %v", err)
+	}
+	want := []string{"start", "drain", "request done", "stop", "hook 2", "hook 1"}
+	if got := journal.list(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("order %v, want %v", got, want)
+	}
+	if _, err := http.Get("http://" + addr + "/slow"); err == nil {
+		t.Fatal("server still listening")
+	}
+}
+
+func TestRunListenError(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	journal := &serverJournal{}
+	a := New(nil)
+	a.AddRouters(&serverRouter{journal: journal})
+	if err := a.Run(context.Background(), ServerConfig{Addr: l.Addr().String()}); err == nil {
+		t.Fatal("run on a busy address succeeded")
+	}
+	// the started routers are stopped
+	if got, want := journal.list(), []string{"start", "stop"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("order %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,400 @@
+package ginx
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/miajio/gin-screw/pkg/stringutil"
+)
+
+// SignAlgorithm request signature algorithm
+type SignAlgorithm string
+
+const (
+	SignMD5        SignAlgorithm = "MD5"         // md5(params&key=secret)
+	SignSHA256     SignAlgorithm = "SHA256"      // sha256(params&key=secret)
+	SignHMACSHA256 SignAlgorithm = "HMAC-SHA256" // hmac-sha256(secret, params)
+)
+
+// signature headers, the query or form params appKey, timestamp, nonce and sign are accepted too
+const (
+	AppKeyHeader    = "X-App-Key"
+	TimestampHeader = "X-Timestamp"
+	NonceHeader     = "X-Nonce"
+	SignHeader      = "X-Sign"
+)
+
+// signature params
+const (
+	appKeyParam    = "appKey"
+	timestampParam = "timestamp"
+	nonceParam     = "nonce"
+	signParam      = "sign"
+	bodyParam      = "body"
+)
+
+var (
+	ErrSignMissing   = NewBizError(40101, http.StatusUnauthorized, "sign.missing", "appKey, timestamp, nonce and sign are required")
+	ErrSignAppKey    = NewBizError(40102, http.StatusUnauthorized, "sign.app_key_invalid", "app key invalid")
+	ErrSignTimestamp = NewBizError(40103, http.StatusUnauthorized, "sign.timestamp_expired", "timestamp out of the allowed window")
+	ErrSignReplay    = NewBizError(40104, http.StatusUnauthorized, "sign.nonce_replayed", "nonce already used")
+	ErrSignInvalid   = NewBizError(40105, http.StatusUnauthorized, "sign.invalid", "signature invalid")
+)
+
+// ErrCredentialNotFound returned by a CredentialStore for an unknown app key
+var ErrCredentialNotFound = errors.New("credential not found")
+
+// Credential app credential
+type Credential struct {
+	AppKey    string
+	Secret    string
+	Algorithm SignAlgorithm // default the SignConfig algorithm
+}
+
+// CredentialStore app credential lookup
+type CredentialStore interface {
+	Credential(ctx context.Context, appKey string) (*Credential, error)
+}
+
+// MemoryCredentialStore in-memory credential store
+type MemoryCredentialStore map[string]Credential
+
+// Credential implement CredentialStore
+func (s MemoryCredentialStore) Credential(_ context.Context, appKey string) (*Credential, error) {
+	if c, ok := s[appKey]; ok {
+		return &c, nil
+	}
+	return nil, ErrCredentialNotFound
+}
+
+// NonceStore remember the nonces seen in the timestamp window
+type NonceStore interface {
+	// Remember return false if the key was already remembered and did not expire
+	Remember(ctx context.Context, key string, ttl time.Duration) (bool, error)
+}
+
+// MemoryNonceStore in-memory nonce store
+type MemoryNonceStore struct {
+	mu    sync.Mutex
+	items map[string]time.Time
+	adds  int
+	now   func() time.Time
+}
+
+// NewMemoryNonceStore create a memory nonce store
+func NewMemoryNonceStore() *MemoryNonceStore {
+	return &MemoryNonceStore{items: make(map[string]time.Time), now: time.Now}
+}
+
+// Remember implement NonceStore
+func (s *MemoryNonceStore) Remember(_ context.Context, key string, ttl time.Duration) (bool, error) {
+	now := s.now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.adds++
+	if s.adds%1024 == 0 {
+		for k, expire := range s.items {
+			if now.After(expire) {
+				delete(s.items, k)
+			}
+		}
+	}
+	if expire, ok := s.items[key]; ok && now.Before(expire) {
+		return false, nil
+	}
+	s.items[key] = now.Add(ttl)
+	return true, nil
+}
+
+// SignConfig request signing middleware config
+type SignConfig struct {
+	Credentials CredentialStore // required
+	Nonces      NonceStore      // default a MemoryNonceStore shared by every SignAuth of the process
+	Algorithm   SignAlgorithm   // default SignHMACSHA256
+	Window      time.Duration   // max difference between the timestamp and the server time, default 5m
+	MaxBody     int64           // max signed body bytes, default 1MB
+}
+
+const appKeyKey = "ginx.appKey"
+
+// defaultNonces nonce store of the SignAuth middlewares without Nonces,
+// shared so a nonce used on one route can not be replayed on another
+var defaultNonces = NewMemoryNonceStore()
+
+// SignAuth authenticate requests signed by an app key
+//
+// the signed string is the request method and the escaped path, each followed by a newline, then
+// the query params, the form params, or the raw body as the body param, plus appKey, timestamp
+// and nonce, without sign and empty values, sorted by name, query escaped and joined as
+// k1=v1&k2=v2; the escaped values of a repeated param are joined by a comma.
+// MD5 and SHA256 hash the string followed by &key=secret, HMAC-SHA256 uses the secret as key,
+// the sign is the hex digest in any case
+//
+// the app key becomes the SubjectClaim of the request
+func SignAuth(cfg SignConfig) gin.HandlerFunc {
+	if cfg.Credentials == nil {
+		panic("sign auth needs a credential store")
+	}
+	if cfg.Nonces == nil {
+		cfg.Nonces = defaultNonces
+	}
+	if cfg.Algorithm == "" {
+		cfg.Algorithm = SignHMACSHA256
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = 5 * time.Minute
+	}
+	if cfg.MaxBody <= 0 {
+		cfg.MaxBody = 1 << 20
+	}
+
+	return func(c *gin.Context) {
+		params, sign, err := signParams(c, cfg.MaxBody)
+		if err != nil {
+			Fail(c, err)
+			return
+		}
+		appKey, nonce := params.Get(appKeyParam), params.Get(nonceParam)
+		ts, tsErr := strconv.ParseInt(params.Get(timestampParam), 10, 64)
+		if appKey == "" || nonce == "" || sign == "" || tsErr != nil {
+			Fail(c, ErrSignMissing)
+			return
+		}
+
+		ctx := c.Request.Context()
+		cred, err := cfg.Credentials.Credential(ctx, appKey)
+		if errors.Is(err, ErrCredentialNotFound) || err == nil && cred == nil {
+			Fail(c, ErrSignAppKey)
+			return
+		}
+		if err != nil {
+			Fail(c, err)
+			return
+		}
+		if d := time.Since(time.Unix(ts, 0)); d > cfg.Window || d < -cfg.Window {
+			Fail(c, ErrSignTimestamp)
+			return
+		}
+		algorithm := cred.Algorithm
+		if algorithm == "" {
+			algorithm = cfg.Algorithm
+		}
+		expected, err := Sign(algorithm, cred.Secret, c.Request.Method, c.Request.URL.EscapedPath(), params)
+		if err != nil {
+			Fail(c, err)
+			return
+		}
+		if !hmac.Equal([]byte(expected), []byte(strings.ToLower(sign))) {
+			Fail(c, ErrSignInvalid)
+			return
+		}
+		// the nonce is remembered only for valid signatures, so nobody can burn the nonces of others
+		fresh, err := cfg.Nonces.Remember(ctx, appKey+":"+nonce, 2*cfg.Window)
+		if err != nil {
+			Fail(c, err)
+			return
+		}
+		if !fresh {
+			Fail(c, ErrSignReplay)
+			return
+		}
+
+		c.Set(appKeyKey, appKey)
+		if Claims(c) == nil {
+			SetClaims(c, map[string]string{SubjectClaim: appKey})
+		}
+		c.Next()
+	}
+}
+
+// AppKey app key of the signed request
+func AppKey(c *gin.Context) string {
+	return c.GetString(appKeyKey)
+}
+
+// signParams collect the signed params and the sign of the request, the body is restored
+func signParams(c *gin.Context, maxBody int64) (url.Values, string, error) {
+	params := url.Values{}
+	for k, v := range c.Request.URL.Query() {
+		params[k] = v
+	}
+	if c.Request.Body != nil && c.Request.Body != http.NoBody {
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxBody+1))
+		if err != nil {
+			return nil, "", ErrBadRequest.Wrap(err)
+		}
+		if int64(len(body)) > maxBody {
+			return nil, "", ErrPayloadTooLarge
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		if err := addBodyParams(params, c.ContentType(), body); err != nil {
+			return nil, "", ErrBadRequest.Wrap(err)
+		}
+	}
+	for header, param := range map[string]string{AppKeyHeader: appKeyParam, TimestampHeader: timestampParam, NonceHeader: nonceParam} {
+		if v := c.GetHeader(header); v != "" {
+			params.Set(param, v)
+		}
+	}
+	sign := c.GetHeader(SignHeader)
+	if sign == "" {
+		sign = params.Get(signParam)
+	}
+	return params, sign, nil
+}
+
+// addBodyParams add the form params, or the raw body as the body param
+func addBodyParams(params url.Values, contentType string, body []byte) error {
+	if len(body) == 0 {
+		return nil
+	}
+	if contentType != binding.MIMEPOSTForm {
+		params.Set(bodyParam, string(body))
+		return nil
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range form {
+		params[k] = append(params[k], v...)
+	}
+	return nil
+}
+
+// SignString the canonical string of the request
+func SignString(method, path string, params url.Values) string {
+	keys := make([]string, 0, len(params))
+	for k, v := range params {
+		if k == signParam || len(v) == 0 || strings.Join(v, "") == "" {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var sb strings.Builder
+	sb.WriteString(strings.ToUpper(method))
+	sb.WriteByte('\n')
+	sb.WriteString(path)
+	sb.WriteByte('\n')
+	for i, k := range keys {
+		if i > 0 {
+			sb.WriteByte('&')
+		}
+		sb.WriteString(url.QueryEscape(k))
+		sb.WriteByte('=')
+		for j, v := range params[k] {
+			if j > 0 {
+				sb.WriteByte(',')
+			}
+			sb.WriteString(url.QueryEscape(v))
+		}
+	}
+	return sb.String()
+}
+
+// Sign sign the request with the secret
+func Sign(algorithm SignAlgorithm, secret, method, path string, params url.Values) (string, error) {
+	s := SignString(method, path, params)
+	switch algorithm {
+	case SignMD5:
+		return stringutil.Md5(s + "&key=" + secret), nil
+	case SignSHA256:
+		return stringutil.Sha256(s + "&key=" + secret), nil
+	case SignHMACSHA256:
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(s))
+		return hex.EncodeToString(mac.Sum(nil)), nil
+	}
+	return "", fmt.Errorf("unknown sign algorithm %q", algorithm)
+}
+
+// Signer client side request signer
+type Signer struct {
+	AppKey    string
+	Secret    string
+	Algorithm SignAlgorithm    // default SignHMACSHA256
+	Now       func() time.Time // default time.Now
+}
+
+// SignRequest sign the request and set the signature headers
+func (s *Signer) SignRequest(req *http.Request) error {
+	params := url.Values{}
+	for k, v := range req.URL.Query() {
+		params[k] = v
+	}
+	if req.Body != nil && req.Body != http.NoBody {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+		contentType, _, _ := strings.Cut(req.Header.Get("Content-Type"), ";")
+		if err := addBodyParams(params, strings.TrimSpace(contentType), body); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now
+	if s.Now != nil {
+		now = s.Now
+	}
+	algorithm := s.Algorithm
+	if algorithm == "" {
+		algorithm = SignHMACSHA256
+	}
+	ts, nonce := strconv.FormatInt(now().Unix(), 10), randomHex(16)
+	params.Set(appKeyParam, s.AppKey)
+	params.Set(timestampParam, ts)
+	params.Set(nonceParam, nonce)
+	sign, err := Sign(algorithm, s.Secret, req.Method, req.URL.EscapedPath(), params)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(AppKeyHeader, s.AppKey)
+	req.Header.Set(TimestampHeader, ts)
+	req.Header.Set(NonceHeader, nonce)
+	req.Header.Set(SignHeader, sign)
+	return nil
+}
+
+// RoundTripper sign every request sent through base, default http.DefaultTransport
+func (s *Signer) RoundTripper(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		req = req.Clone(req.Context())
+		if err := s.SignRequest(req); err != nil {
+			return nil, err
+		}
+		return base.RoundTrip(req)
+	})
+}
+
+// roundTripperFunc http.RoundTripper from a func
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip call the func
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
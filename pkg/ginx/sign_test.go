@@ -0,0 +1,167 @@
+package ginx
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// signedRequest build a request signed with the headers
+func signedRequest(t *testing.T, path string, algorithm SignAlgorithm, appKey, secret, nonce string, ts time.Time, query, body string) *http.Request {
+	params, err := url.ParseQuery(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body != "" {
+		params.Set(bodyParam, body)
+	}
+	stamp := strconv.FormatInt(ts.Unix(), 10)
+	params.Set(appKeyParam, appKey)
+	params.Set(timestampParam, stamp)
+	params.Set(nonceParam, nonce)
+	sign, err := Sign(algorithm, secret, http.MethodPost, path, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, path+"?"+query, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(AppKeyHeader, appKey)
+	req.Header.Set(TimestampHeader, stamp)
+	req.Header.Set(NonceHeader, nonce)
+	req.Header.Set(SignHeader, sign)
+	return req
+}
+
+func TestSignAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.POST("/orders", SignAuth(SignConfig{
+		Credentials: MemoryCredentialStore{
+			"app":    {AppKey: "app", Secret: "s3cret"},
+			"legacy": {AppKey: "legacy", Secret: "old", Algorithm: SignMD5},
+		},
+		Nonces: NewMemoryNonceStore(),
+		Window: time.Minute,
+	}), func(c *gin.Context) {
+		OK(c, AppKey(c))
+	})
+
+	now := time.Now()
+	tests := []struct {
+		name      string
+		algorithm SignAlgorithm
+		appKey    string
+		secret    string
+		nonce     string
+		ts        time.Time
+		tamper    func(r *http.Request)
+		code      int // business code, 0 is success
+	}{
+		{"valid", SignHMACSHA256, "app", "s3cret", "n1", now, nil, 0},
+		{"replayed nonce", SignHMACSHA256, "app", "s3cret", "n1", now, nil, ErrSignReplay.Code},
+		{"same nonce of another app", SignMD5, "legacy", "old", "n1", now, nil, 0},
+		{"timestamp too old", SignHMACSHA256, "app", "s3cret", "n2", now.Add(-2 * time.Minute), nil, ErrSignTimestamp.Code},
+		{"timestamp in the future", SignHMACSHA256, "app", "s3cret", "n3", now.Add(2 * time.Minute), nil, ErrSignTimestamp.Code},
+		{"skew within the window", SignHMACSHA256, "app", "s3cret", "n4", now.Add(-30 * time.Second), nil, 0},
+		{"wrong secret", SignHMACSHA256, "app", "guess", "n5", now, nil, ErrSignInvalid.Code},
+		{"invalid sign does not burn the nonce", SignHMACSHA256, "app", "s3cret", "n5", now, nil, 0},
+		{"unknown app", SignHMACSHA256, "nobody", "s3cret", "n6", now, nil, ErrSignAppKey.Code},
+		{"tampered body", SignHMACSHA256, "app", "s3cret", "n7", now, func(r *http.Request) {
+			r.Body = http.NoBody
+		}, ErrSignInvalid.Code},
+		{"missing sign", SignHMACSHA256, "app", "s3cret", "n8", now, func(r *http.Request) {
+			r.Header.Del(SignHeader)
+		}, ErrSignMissing.Code},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := signedRequest(t, "/orders", tt.algorithm, tt.appKey, tt.secret, tt.nonce, tt.ts, "page=1&tag=b&tag=a", `{"amount":10}`)
+			if tt.tamper != nil {
+				tt.tamper(req)
+			}
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, req)
+			var resp Response
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("body %q: %v", w.Body.String(), err)
+			}
+			if resp.Code != tt.code {
+				t.Fatalf("code %d, want %d: %s", resp.Code, tt.code, w.Body.String())
+			}
+			if tt.code == 0 && resp.Data != tt.appKey {
+				t.Fatalf("app key %v, want %s", resp.Data, tt.appKey)
+			}
+		})
+	}
+}
+
+func TestSignAuthCrossRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	creds := MemoryCredentialStore{"app": {AppKey: "app", Secret: "s3cret"}}
+	// two SignAuth without Nonces, as mounted by two groups or two engines of the process
+	e := gin.New()
+	e.POST("/orders", SignAuth(SignConfig{Credentials: creds}), func(c *gin.Context) { OK(c, "orders") })
+	e.POST("/refunds", SignAuth(SignConfig{Credentials: creds}), func(c *gin.Context) { OK(c, "refunds") })
+	other := gin.New()
+	other.POST("/orders", SignAuth(SignConfig{Credentials: creds}), func(c *gin.Context) { OK(c, "orders") })
+
+	nonce := randomHex(16)
+	send := func(e *gin.Engine, req *http.Request) int {
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		var resp Response
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("body %q: %v", w.Body.String(), err)
+		}
+		return resp.Code
+	}
+	orders := func() *http.Request {
+		return signedRequest(t, "/orders", SignHMACSHA256, "app", "s3cret", nonce, time.Now(), "page=1", `{"amount":10}`)
+	}
+
+	// the path is signed, a request signed for /orders is rejected by /refunds
+	moved := orders()
+	moved.URL.Path = "/refunds"
+	if code := send(e, moved); code != ErrSignInvalid.Code {
+		t.Fatalf("moved request code %d, want %d", code, ErrSignInvalid.Code)
+	}
+	if code := send(e, orders()); code != 0 {
+		t.Fatalf("first request code %d", code)
+	}
+	// the nonce store is shared, the same request replayed to another SignAuth is rejected
+	if code := send(other, orders()); code != ErrSignReplay.Code {
+		t.Fatalf("replay code %d, want %d", code, ErrSignReplay.Code)
+	}
+}
+
+func TestSignString(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b url.Values
+		same bool
+	}{
+		{"order of the values", url.Values{"tag": {"a", "b"}}, url.Values{"tag": {"a", "b"}}, true},
+		{"separator in a value", url.Values{"a": {"1&b=2"}}, url.Values{"a": {"1"}, "b": {"2"}}, false},
+		{"comma in a value", url.Values{"tag": {"a,b"}}, url.Values{"tag": {"a", "b"}}, false},
+		{"equal sign in a key", url.Values{"a=1": {"2"}}, url.Values{"a": {"1=2"}}, false},
+		{"empty values skipped", url.Values{"a": {"1"}, "b": {""}}, url.Values{"a": {"1"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := SignString(http.MethodPost, "/orders", tt.a), SignString(http.MethodPost, "/orders", tt.b)
+			if (a == b) != tt.same {
+				t.Fatalf("%q and %q same %v, want %v", a, b, a == b, tt.same)
+			}
+		})
+	}
+	if SignString(http.MethodGet, "/orders", nil) == SignString(http.MethodDelete, "/orders", nil) {
+		t.Fatal("the method is not signed")
+	}
+}
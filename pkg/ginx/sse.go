@@ -0,0 +1,332 @@
+package ginx
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// SSEConfig server-sent events hub config
+type SSEConfig struct {
+	Buffer    int           // buffered events per client, a client falling behind is disconnected, default 64
+	History   int           // events kept per topic for the Last-Event-ID replay, default 256
+	ReplayTTL time.Duration // events older than it are not replayed, and the topics without clients are deleted, default 5m
+	Heartbeat time.Duration // comment line sent to idle clients, default 15s
+	Retry     time.Duration // reconnect delay sent to the clients, 0 the browser default
+}
+
+// SSEEvent published event
+type SSEEvent struct {
+	ID    string
+	Topic string
+	Event string // event name, empty is message
+	Data  string
+	seq   uint64
+	at    time.Time
+}
+
+// SSEHub fan-out of server-sent events to the subscribed clients
+// event ids are increasing over the hub, so a reconnecting client sends its Last-Event-ID
+// and gets the events it missed that are still in the topic history
+type SSEHub struct {
+	cfg    SSEConfig
+	mu     sync.Mutex
+	seq    uint64
+	topics map[string]*sseTopic
+	closed bool
+	adds   int
+	now    func() time.Time
+}
+
+// sseTopic clients and history of a topic
+type sseTopic struct {
+	clients map[*sseClient]struct{}
+	history []SSEEvent // oldest first, at most History events
+}
+
+// sseClient one subscribed connection
+type sseClient struct {
+	events chan SSEEvent
+	done   chan struct{} // closed when the hub drops the client
+	once   sync.Once
+}
+
+// drop disconnect the client
+func (cl *sseClient) drop() {
+	cl.once.Do(func() { close(cl.done) })
+}
+
+// NewSSEHub create a server-sent events hub
+func NewSSEHub(cfg SSEConfig) *SSEHub {
+	if cfg.Buffer <= 0 {
+		cfg.Buffer = 64
+	}
+	if cfg.History <= 0 {
+		cfg.History = 256
+	}
+	if cfg.Heartbeat <= 0 {
+		cfg.Heartbeat = 15 * time.Second
+	}
+	if cfg.ReplayTTL <= 0 {
+		cfg.ReplayTTL = 5 * time.Minute
+	}
+	return &SSEHub{cfg: cfg, topics: make(map[string]*sseTopic), now: time.Now}
+}
+
+// Publish send the event to the clients of the topic and return its id
+// data is sent as is when it is a string or []byte, as json otherwise
+func (h *SSEHub) Publish(topic, event string, data any) (string, error) {
+	var payload string
+	switch v := data.(type) {
+	case string:
+		payload = v
+	case []byte:
+		payload = string(v)
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		payload = string(b)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := h.now()
+	h.seq++
+	e := SSEEvent{ID: strconv.FormatUint(h.seq, 10), Topic: topic, Event: event, Data: payload, seq: h.seq, at: now}
+	t := h.topic(topic)
+	t.history = append(t.history, e)
+	if len(t.history) > h.cfg.History {
+		t.history = append(t.history[:0:0], t.history[len(t.history)-h.cfg.History:]...)
+	}
+	// the topics published once are deleted from time to time, so the hub does not grow with them
+	h.adds++
+	if h.adds%1024 == 0 {
+		h.sweep(now)
+	}
+	for cl := range t.clients {
+		select {
+		case cl.events <- e:
+		default:
+			// the client falls behind, it reconnects and replays from its Last-Event-ID
+			h.remove(cl)
+			cl.drop()
+		}
+	}
+	return e.ID, nil
+}
+
+// topic get or create the topic, h.mu is held
+func (h *SSEHub) topic(name string) *sseTopic {
+	t, ok := h.topics[name]
+	if !ok {
+		t = &sseTopic{clients: make(map[*sseClient]struct{})}
+		h.topics[name] = t
+	}
+	return t
+}
+
+// subscribe register a client and return the events after lastID, h.mu is taken once
+// so no event is lost or sent twice between the replay and the live events
+func (h *SSEHub) subscribe(topics []string, lastID string) (*sseClient, []SSEEvent) {
+	cl := &sseClient{events: make(chan SSEEvent, h.cfg.Buffer), done: make(chan struct{})}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		cl.drop()
+		return cl, nil
+	}
+	last, replay := uint64(0), false
+	if lastID != "" {
+		if n, err := strconv.ParseUint(lastID, 10, 64); err == nil {
+			last, replay = n, true
+		}
+	}
+	var missed []SSEEvent
+	oldest := h.now().Add(-h.cfg.ReplayTTL)
+	for _, name := range topics {
+		t := h.topic(name)
+		t.clients[cl] = struct{}{}
+		if !replay {
+			continue
+		}
+		for _, e := range t.history {
+			if e.seq > last && !e.at.Before(oldest) {
+				missed = append(missed, e)
+			}
+		}
+	}
+	sort.Slice(missed, func(i, j int) bool { return missed[i].seq < missed[j].seq })
+	return cl, missed
+}
+
+// unsubscribe remove the client from all the topics
+func (h *SSEHub) unsubscribe(cl *sseClient) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.remove(cl)
+}
+
+// remove remove the client and sweep the topics, h.mu is held
+func (h *SSEHub) remove(cl *sseClient) {
+	for _, t := range h.topics {
+		delete(t.clients, cl)
+	}
+	h.sweep(h.now())
+}
+
+// sweep drop the events out of the replay window, and delete the topics
+// without clients and history, h.mu is held
+func (h *SSEHub) sweep(now time.Time) {
+	oldest := now.Add(-h.cfg.ReplayTTL)
+	for name, t := range h.topics {
+		i := sort.Search(len(t.history), func(i int) bool { return !t.history[i].at.Before(oldest) })
+		if i > 0 {
+			t.history = append(t.history[:0:0], t.history[i:]...)
+		}
+		if len(t.clients) == 0 && len(t.history) == 0 {
+			delete(h.topics, name)
+		}
+	}
+}
+
+// Clients number of the clients subscribed to the topic
+func (h *SSEHub) Clients(topic string) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if t, ok := h.topics[topic]; ok {
+		return len(t.clients)
+	}
+	return 0
+}
+
+// Close disconnect all the clients and refuse new ones
+// demo: ginx.OnDrain(hub.Close), so the streams do not hold the graceful shutdown
+func (h *SSEHub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for name, t := range h.topics {
+		for cl := range t.clients {
+			cl.drop()
+		}
+		delete(h.topics, name)
+	}
+}
+
+// Serve stream the events of the topics to the request until the client goes away
+func (h *SSEHub) Serve(c *gin.Context, topics ...string) {
+	lastID := c.GetHeader("Last-Event-ID")
+	if lastID == "" {
+		lastID = c.Query("lastEventId")
+	}
+	cl, missed := h.subscribe(topics, lastID)
+	defer h.unsubscribe(cl)
+
+	// the server WriteTimeout would end the stream
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		logger().Warn("sse write deadline not cleared, the server WriteTimeout ends the stream", zap.Error(err))
+	}
+	header := c.Writer.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	var sb strings.Builder
+	if h.cfg.Retry > 0 {
+		sb.WriteString("retry: " + strconv.FormatInt(h.cfg.Retry.Milliseconds(), 10) + "\n\n")
+	}
+	for _, e := range missed {
+		writeSSE(&sb, e)
+	}
+	if !h.flush(c, &sb) {
+		return
+	}
+
+	heartbeat := time.NewTicker(h.cfg.Heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case e := <-cl.events:
+			writeSSE(&sb, e)
+		case <-heartbeat.C:
+			sb.WriteString(": ping\n\n")
+		case <-cl.done:
+			return
+		case <-c.Request.Context().Done():
+			return
+		}
+		if !h.flush(c, &sb) {
+			return
+		}
+	}
+}
+
+// flush write the buffered lines, false if the client is gone
+func (h *SSEHub) flush(c *gin.Context, sb *strings.Builder) bool {
+	defer sb.Reset()
+	if _, err := c.Writer.WriteString(sb.String()); err != nil {
+		logger().Debug("sse client gone", zap.Error(err))
+		return false
+	}
+	c.Writer.Flush()
+	return true
+}
+
+// writeSSE encode the event, multi-line data is split into data lines
+func writeSSE(sb *strings.Builder, e SSEEvent) {
+	sb.WriteString("id: " + e.ID + "\n")
+	if e.Event != "" {
+		sb.WriteString("event: " + e.Event + "\n")
+	}
+	for _, line := range strings.Split(strings.ReplaceAll(e.Data, "\r\n", "\n"), "\n") {
+		sb.WriteString("data: " + line + "\n")
+	}
+	sb.WriteString("\n")
+}
+
+// SSEHandlerConfig subscription handler config
+type SSEHandlerConfig struct {
+	Secret string                                 // pkg/jwt secret, required
+	Topics func(c *gin.Context) ([]string, error) // topics the authenticated subscriber may read, required
+}
+
+// Handler subscription handler authenticating the subscribers with pkg/jwt tokens
+// the token is the Authorization Bearer header or the token query param, as EventSource
+// can not set headers; Topics derives the topics from the claims through Claims,
+// so a client can not subscribe to the topics of another one
+//
+//	demo: Topics returning "user:" + Claims(c)[SubjectClaim] pushes the notifications of one user
+func (h *SSEHub) Handler(cfg SSEHandlerConfig) gin.HandlerFunc {
+	if cfg.Secret == "" {
+		panic("sse handler needs a jwt secret")
+	}
+	if cfg.Topics == nil {
+		panic("sse handler needs a Topics func")
+	}
+	return func(c *gin.Context) {
+		if err := authenticate(c, cfg.Secret); err != nil {
+			Fail(c, err)
+			return
+		}
+		topics, err := cfg.Topics(c)
+		if err != nil {
+			Fail(c, err)
+			return
+		}
+		if len(topics) == 0 {
+			Fail(c, ErrBadRequest.WithMessage("no topic to subscribe"))
+			return
+		}
+		h.Serve(c, topics...)
+	}
+}
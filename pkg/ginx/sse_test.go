@@ -0,0 +1,96 @@
+package ginx
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/miajio/gin-screw/pkg/jwt"
+)
+
+func TestSSEHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const secret = "sse-secret"
+	token, err := jwt.EncryptionToken(map[string]string{SubjectClaim: "42"}, secret, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hub := NewSSEHub(SSEConfig{History: 8})
+	e := gin.New()
+	e.GET("/events", hub.Handler(SSEHandlerConfig{
+		Secret: secret,
+		Topics: func(c *gin.Context) ([]string, error) {
+			return []string{"user:" + Claims(c)[SubjectClaim]}, nil
+		},
+	}))
+	_, _ = hub.Publish("user:42", "note", "mine")
+	_, _ = hub.Publish("user:7", "note", "not mine")
+
+	tests := []struct {
+		name   string
+		query  string
+		status int
+		body   string
+	}{
+		{"no token", "", http.StatusUnauthorized, ""},
+		{"bad token", "?token=x", http.StatusUnauthorized, ""},
+		{"topics from the claims", "?token=" + token + "&lastEventId=0&topic=user:7", http.StatusOK, "data: mine\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			req := httptest.NewRequest(http.MethodGet, "/events"+tt.query, nil).WithContext(ctx)
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, req)
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+			if tt.body != "" && !strings.Contains(w.Body.String(), tt.body) {
+				t.Fatalf("body %q, want %q", w.Body.String(), tt.body)
+			}
+			if strings.Contains(w.Body.String(), "not mine") {
+				t.Fatalf("event of another subscriber sent: %q", w.Body.String())
+			}
+		})
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Handler without Topics did not panic")
+		}
+	}()
+	hub.Handler(SSEHandlerConfig{Secret: secret})
+}
+
+func TestSSEReplayTTL(t *testing.T) {
+	now := time.Now()
+	hub := NewSSEHub(SSEConfig{ReplayTTL: time.Minute})
+	hub.now = func() time.Time { return now }
+
+	_, _ = hub.Publish("old", "", "a")
+	_, _ = hub.Publish("live", "", "b")
+	now = now.Add(40 * time.Second)
+	id, _ := hub.Publish("live", "", "c")
+	now = now.Add(40 * time.Second)
+
+	// the events out of the window are not replayed
+	cl, missed := hub.subscribe([]string{"live", "old"}, "0")
+	if len(missed) != 1 || missed[0].ID != id {
+		t.Fatalf("missed %v, want the event %s only", missed, id)
+	}
+	// the topic without clients and recent events is deleted, the other one keeps its recent event
+	hub.unsubscribe(cl)
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	if _, ok := hub.topics["old"]; ok {
+		t.Fatal("expired topic not deleted")
+	}
+	if live, ok := hub.topics["live"]; !ok || len(live.history) != 1 {
+		t.Fatalf("live topic %+v, want its recent event", live)
+	}
+}
@@ -0,0 +1,269 @@
+package ginx
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	filieutil "github.com/miajio/gin-screw/pkg/filie_util"
+)
+
+// StaticConfig static assets config
+type StaticConfig struct {
+	FS          fs.FS          // assets, demo: an embed.FS narrowed with fs.Sub
+	Dir         string         // assets folder, used when FS is nil
+	Prefix      string         // mount path, default /
+	Index       string         // default index.html
+	SPA         bool           // serve the index for unknown paths without extension, for history mode routers
+	APIPrefixes []string       // paths never served, unknown api routes get the json 404, demo: /api
+	Hashed      *regexp.Regexp // file names cached as immutable, default names like app.3f2a9c1b.js or index-B3f9aK1z.js
+	MaxAge      time.Duration  // max-age of the other files, default 1h
+}
+
+// hashedName default hashed asset name, the hash must hold a digit
+var hashedName = regexp.MustCompile(`[.-]([0-9a-zA-Z_]{8,})\.[a-z0-9]+$`)
+
+// staticMount compiled static config
+type staticMount struct {
+	cfg   StaticConfig
+	fsys  fs.FS
+	etags sync.Map // path -> etag
+}
+
+// Static serve the assets on the paths no route matches
+// routes always win, so the assets never shadow the api;
+// index.html is served with no-cache, hashed assets as immutable,
+// a .gz variant next to a file is sent to the clients accepting gzip
+// the paths no asset matches go to the App.NoRoute handlers, or get the json 404;
+// NoRoute handlers set on the gin engine directly are replaced
+func (a *App) Static(cfg StaticConfig) error {
+	m, err := newStaticMount(cfg)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.statics = append(a.statics, m)
+	a.installNoRoute()
+	return nil
+}
+
+// Static serve the assets on the singleton app
+func Static(cfg StaticConfig) error {
+	check()
+	return this.Static(cfg)
+}
+
+// NoRoute handlers of the paths no route matches, run after the static assets
+func (a *App) NoRoute(handlers ...gin.HandlerFunc) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.noRoute = append([]gin.HandlerFunc(nil), handlers...)
+	a.installNoRoute()
+}
+
+// NoRoute handlers of the paths no route matches on the singleton app
+func NoRoute(handlers ...gin.HandlerFunc) {
+	check()
+	this.NoRoute(handlers...)
+}
+
+// installNoRoute set the engine NoRoute handler, a.mu is held
+func (a *App) installNoRoute() {
+	if len(a.statics) == 0 {
+		a.engine.NoRoute(a.noRoute...)
+		return
+	}
+	// longer prefixes first, so /admin is served before /
+	mounts := append([]*staticMount(nil), a.statics...)
+	for i := 1; i < len(mounts); i++ {
+		for j := i; j > 0 && len(mounts[j].cfg.Prefix) > len(mounts[j-1].cfg.Prefix); j-- {
+			mounts[j], mounts[j-1] = mounts[j-1], mounts[j]
+		}
+	}
+	handlers := a.noRoute
+	a.engine.NoRoute(func(c *gin.Context) {
+		for _, m := range mounts {
+			if m.serve(c) {
+				return
+			}
+		}
+		if len(handlers) == 0 {
+			Fail(c, ErrNotFound)
+			return
+		}
+		for _, h := range handlers {
+			if c.IsAborted() {
+				return
+			}
+			h(c)
+		}
+	})
+}
+
+// newStaticMount validate the config
+func newStaticMount(cfg StaticConfig) (*staticMount, error) {
+	fsys := cfg.FS
+	if fsys == nil {
+		if cfg.Dir == "" {
+			return nil, errors.New("static needs a FS or a Dir")
+		}
+		f, err := filieutil.New(cfg.Dir)
+		if err != nil {
+			return nil, err
+		}
+		if !f.IsDir() {
+			return nil, fmt.Errorf("%s path not a folder", cfg.Dir)
+		}
+		fsys = os.DirFS(f.GetPath())
+	}
+	cfg.Prefix = "/" + strings.Trim(cfg.Prefix, "/")
+	if cfg.Index == "" {
+		cfg.Index = "index.html"
+	}
+	if cfg.MaxAge <= 0 {
+		cfg.MaxAge = time.Hour
+	}
+	prefixes := make([]string, len(cfg.APIPrefixes))
+	for i, p := range cfg.APIPrefixes {
+		prefixes[i] = "/" + strings.Trim(p, "/")
+	}
+	cfg.APIPrefixes = prefixes
+	if cfg.SPA {
+		if _, err := fs.Stat(fsys, cfg.Index); err != nil {
+			return nil, fmt.Errorf("static index: %w", err)
+		}
+	}
+	return &staticMount{cfg: cfg, fsys: fsys}, nil
+}
+
+// under report whether the path is the prefix or below it
+func under(p, prefix string) bool {
+	return prefix == "/" || p == prefix || strings.HasPrefix(p, prefix+"/")
+}
+
+// serve serve the request, false if the path is not for this mount
+func (m *staticMount) serve(c *gin.Context) bool {
+	p := c.Request.URL.Path
+	if !under(p, m.cfg.Prefix) {
+		return false
+	}
+	for _, api := range m.cfg.APIPrefixes {
+		if under(p, api) {
+			return false
+		}
+	}
+	if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+		return false
+	}
+
+	name := strings.TrimPrefix(path.Clean("/"+strings.TrimPrefix(p, m.cfg.Prefix)), "/")
+	if name == "" {
+		name = m.cfg.Index
+	}
+	if info, err := fs.Stat(m.fsys, name); err == nil && info.IsDir() {
+		name = path.Join(name, m.cfg.Index)
+	}
+	if _, err := fs.Stat(m.fsys, name); err != nil {
+		if !m.cfg.SPA || path.Ext(name) != "" {
+			return false
+		}
+		name = m.cfg.Index
+	}
+	if err := m.serveFile(c, name); err != nil {
+		Fail(c, ErrInternal.Wrap(err))
+	}
+	return true
+}
+
+// serveFile send the file, or its .gz variant, with the cache headers
+func (m *staticMount) serveFile(c *gin.Context, name string) error {
+	h := c.Writer.Header()
+	switch {
+	case name == m.cfg.Index || strings.HasSuffix(name, "/"+m.cfg.Index):
+		h.Set("Cache-Control", "no-cache")
+	case m.hashed(path.Base(name)):
+		h.Set("Cache-Control", "public, max-age=31536000, immutable")
+	default:
+		h.Set("Cache-Control", "public, max-age="+strconv.Itoa(int(m.cfg.MaxAge.Seconds())))
+	}
+	if ct := mime.TypeByExtension(path.Ext(name)); ct != "" {
+		h.Set("Content-Type", ct)
+	}
+
+	file := name
+	if acceptEncoding(c.GetHeader("Accept-Encoding")) == "gzip" {
+		if _, err := fs.Stat(m.fsys, name+".gz"); err == nil {
+			file = name + ".gz"
+			h.Set("Content-Encoding", "gzip")
+		}
+		h.Add("Vary", "Accept-Encoding")
+	}
+
+	f, err := m.fsys.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(f)
+		if err != nil {
+			return err
+		}
+		content = bytes.NewReader(data)
+	}
+	etag, err := m.etag(file, info, content)
+	if err != nil {
+		return err
+	}
+	h.Set("ETag", etag)
+	http.ServeContent(c.Writer, c.Request, name, info.ModTime(), content)
+	c.Abort()
+	return nil
+}
+
+// hashed report whether the file name holds a content hash
+func (m *staticMount) hashed(name string) bool {
+	if m.cfg.Hashed != nil {
+		return m.cfg.Hashed.MatchString(name)
+	}
+	match := hashedName.FindStringSubmatch(name)
+	return match != nil && strings.ContainsAny(match[1], "0123456789")
+}
+
+// etag strong etag of the file content, computed once per file version
+func (m *staticMount) etag(name string, info fs.FileInfo, content io.ReadSeeker) (string, error) {
+	key := name + "|" + strconv.FormatInt(info.Size(), 10) + "|" + strconv.FormatInt(info.ModTime().UnixNano(), 10)
+	if v, ok := m.etags.Load(key); ok {
+		return v.(string), nil
+	}
+	sum := sha256.New()
+	if _, err := io.Copy(sum, content); err != nil {
+		return "", err
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	etag := `"` + hex.EncodeToString(sum.Sum(nil)[:16]) + `"`
+	m.etags.Store(key, etag)
+	return etag, nil
+}
@@ -0,0 +1,78 @@
+package ginx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newStaticApp(t *testing.T, cfg StaticConfig) *App {
+	gin.SetMode(gin.TestMode)
+	a := New(nil)
+	a.engine.GET("/api/users", func(c *gin.Context) { c.String(http.StatusOK, "users") })
+	if err := a.Static(cfg); err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func TestStatic(t *testing.T) {
+	assets := fstest.MapFS{
+		"index.html":             {Data: []byte("<html>app</html>")},
+		"assets/app.3f2a9c1b.js": {Data: []byte("console.log(1)")},
+		"favicon.ico":            {Data: []byte("icon")},
+	}
+	prefixes := []string{"api/"}
+	a := newStaticApp(t, StaticConfig{FS: assets, SPA: true, APIPrefixes: prefixes})
+	if prefixes[0] != "api/" {
+		t.Fatalf("APIPrefixes of the caller changed to %q", prefixes[0])
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		status int
+		body   string
+		cache  string // Cache-Control
+	}{
+		{"route wins", "/api/users", http.StatusOK, "users", ""},
+		{"index", "/", http.StatusOK, "<html>app</html>", "no-cache"},
+		{"spa fallback", "/users/42", http.StatusOK, "<html>app</html>", "no-cache"},
+		{"hashed asset", "/assets/app.3f2a9c1b.js", http.StatusOK, "console.log(1)", "public, max-age=31536000, immutable"},
+		{"other asset", "/favicon.ico", http.StatusOK, "icon", "public, max-age=3600"},
+		{"missing asset", "/assets/missing.js", http.StatusNotFound, "", ""},
+		{"unknown api route", "/api/orders", http.StatusNotFound, `"code"`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			a.engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+			if !strings.Contains(w.Body.String(), tt.body) {
+				t.Fatalf("body %q, want %q", w.Body.String(), tt.body)
+			}
+			if got := w.Header().Get("Cache-Control"); got != tt.cache {
+				t.Fatalf("Cache-Control %q, want %q", got, tt.cache)
+			}
+		})
+	}
+}
+
+func TestStaticNoRoute(t *testing.T) {
+	a := newStaticApp(t, StaticConfig{FS: fstest.MapFS{"index.html": {Data: []byte("index")}}, Prefix: "/admin"})
+	a.NoRoute(func(c *gin.Context) { c.String(http.StatusNotFound, "custom") })
+
+	for path, want := range map[string]string{"/admin/": "index", "/elsewhere": "custom"} {
+		w := httptest.NewRecorder()
+		a.engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Body.String() != want {
+			t.Fatalf("%s: body %q, want %q", path, w.Body.String(), want)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package ginx
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// TimeoutConfig request timeout middleware config
+type TimeoutConfig struct {
+	Timeout time.Duration // request deadline, required
+	Status  int           // status of the expired requests, http.StatusServiceUnavailable or default http.StatusGatewayTimeout
+}
+
+// Timeout put a deadline on the request context of the route
+// the handlers must pass c.Request.Context() to the calls that can block, so they return once
+// the deadline expires; the response is buffered and an expired request gets the Status
+// instead, unless it already failed with a 5xx. A flushed response is a stream and is sent as is
+//
+// the middleware waits for the handler to return, a handler that ignores the context holds
+// the request past the deadline and the Status is sent when it returns. The headers the
+// handlers set are dropped with the expired response, the ones of the outer middlewares are kept
+//
+// nested timeouts keep the shortest deadline, a limiter after the Timeout waits within the deadline
+func Timeout(cfg TimeoutConfig) gin.HandlerFunc {
+	if cfg.Timeout <= 0 {
+		panic("timeout needs a positive Timeout")
+	}
+	fail := ErrTimeout
+	switch cfg.Status {
+	case 0, http.StatusGatewayTimeout:
+	case http.StatusServiceUnavailable:
+		fail = ErrServiceUnavailable
+	default:
+		panic("timeout status must be 503 or 504")
+	}
+
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), cfg.Timeout)
+		defer cancel()
+		req := c.Request
+		c.Request = req.WithContext(ctx)
+		outer := c.Writer.Header().Clone()
+		w := newBufferWriter(c.Writer)
+		c.Writer = w
+		defer func() {
+			// on panic the recovery middleware writes its error to the client
+			if c.Writer == gin.ResponseWriter(w) {
+				c.Writer = w.ResponseWriter
+			}
+			c.Request = req
+		}()
+
+		c.Next()
+
+		c.Writer = w.ResponseWriter
+		if w.passthrough {
+			return
+		}
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) && w.status < http.StatusInternalServerError {
+			countShed("timeout", "deadline")
+			logger().Warn("request timeout",
+				zap.String("method", req.Method),
+				zap.String("path", req.URL.Path),
+				zap.Duration("timeout", cfg.Timeout))
+			h := c.Writer.Header()
+			for k := range h {
+				delete(h, k)
+			}
+			for k, v := range outer {
+				h[k] = v
+			}
+			Fail(c, fail)
+			return
+		}
+		w.flush()
+	}
+}
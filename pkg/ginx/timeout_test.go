@@ -0,0 +1,79 @@
+package ginx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestTimeout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	slow := func(c *gin.Context) {
+		<-c.Request.Context().Done()
+		c.String(http.StatusOK, "late")
+	}
+	tests := []struct {
+		name    string
+		status  int // TimeoutConfig.Status
+		handler gin.HandlerFunc
+		want    int
+		body    string
+	}{
+		{"in time", 0, func(c *gin.Context) { c.String(http.StatusOK, "ok") }, http.StatusOK, "ok"},
+		{"expired", 0, slow, http.StatusGatewayTimeout, ""},
+		{"expired with 503", http.StatusServiceUnavailable, slow, http.StatusServiceUnavailable, ""},
+		{"5xx is kept", 0, func(c *gin.Context) {
+			<-c.Request.Context().Done()
+			c.String(http.StatusBadGateway, "upstream")
+		}, http.StatusBadGateway, "upstream"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := gin.New()
+			e.GET("/", Timeout(TimeoutConfig{Timeout: 20 * time.Millisecond, Status: tt.status}), tt.handler)
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+			if w.Code != tt.want {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+			if tt.body != "" && w.Body.String() != tt.body {
+				t.Fatalf("body %q, want %q", w.Body.String(), tt.body)
+			}
+		})
+	}
+}
+
+func TestTimeoutHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(func(c *gin.Context) {
+		c.Header("X-Request-ID", "abc")
+	})
+	e.GET("/", Timeout(TimeoutConfig{Timeout: 20 * time.Millisecond}), func(c *gin.Context) {
+		c.SetCookie("session", "1", 60, "/", "", false, true)
+		c.Header("ETag", `"v1"`)
+		c.Header("Cache-Control", "public, max-age=60")
+		<-c.Request.Context().Done()
+		c.Data(http.StatusOK, "text/csv", []byte("late"))
+	})
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusGatewayTimeout {
+		t.Fatalf("status %d, want 504", w.Code)
+	}
+	for _, k := range []string{"Set-Cookie", "ETag", "Cache-Control"} {
+		if v := w.Header().Get(k); v != "" {
+			t.Fatalf("handler header %s: %q leaked on the timeout", k, v)
+		}
+	}
+	if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, "application/json") {
+		t.Fatalf("Content-Type %q, want the json error", got)
+	}
+	if got := w.Header().Get("X-Request-ID"); got != "abc" {
+		t.Fatalf("outer header X-Request-ID %q, want abc", got)
+	}
+}
@@ -0,0 +1,82 @@
+package ginx
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// bufferWriter buffer the response body until the middleware decides what to send
+// the status is kept and the header is written with the buffered body;
+// a flush switches to pass through, so a streamed response is sent as is
+type bufferWriter struct {
+	gin.ResponseWriter
+	status      int
+	buf         bytes.Buffer
+	passthrough bool
+}
+
+// newBufferWriter wrap the writer, the status defaults to 200
+func newBufferWriter(w gin.ResponseWriter) *bufferWriter {
+	return &bufferWriter{ResponseWriter: w, status: http.StatusOK}
+}
+
+// Unwrap the wrapped writer, for http.ResponseController
+func (w *bufferWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// WriteHeader keep the status
+func (w *bufferWriter) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write buffer or pass through
+func (w *bufferWriter) Write(p []byte) (int, error) {
+	if w.passthrough {
+		return w.ResponseWriter.Write(p)
+	}
+	return w.buf.Write(p)
+}
+
+// WriteString buffer or pass through
+func (w *bufferWriter) WriteString(s string) (int, error) {
+	if w.passthrough {
+		return w.ResponseWriter.WriteString(s)
+	}
+	return w.buf.WriteString(s)
+}
+
+// Written report whether the body was written
+func (w *bufferWriter) Written() bool {
+	return w.buf.Len() > 0 || w.ResponseWriter.Written()
+}
+
+// WriteHeaderNow the header is written with the buffered body
+func (w *bufferWriter) WriteHeaderNow() {
+	if w.passthrough {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+// Flush write the buffered body and pass through from now on
+func (w *bufferWriter) Flush() {
+	w.flush()
+	w.ResponseWriter.Flush()
+}
+
+// flush write the buffered body to the client
+func (w *bufferWriter) flush() {
+	if w.passthrough {
+		return
+	}
+	w.passthrough = true
+	if w.buf.Len() == 0 {
+		w.ResponseWriter.WriteHeaderNow()
+		return
+	}
+	_, _ = w.ResponseWriter.Write(w.buf.Bytes())
+	w.buf.Reset()
+}
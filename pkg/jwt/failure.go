@@ -0,0 +1,28 @@
+package jwt
+
+import "sync"
+
+var (
+	failures   = make(map[string]uint64) // 校验失败次数, key 为失败原因
+	failuresMu sync.Mutex
+)
+
+// fail count the validation failure by reason
+func fail(reason string, err error) error {
+	failuresMu.Lock()
+	defer failuresMu.Unlock()
+	failures[reason]++
+	return err
+}
+
+// FailureCounts token validation failures by reason
+// reasons: malformed, expired, not_valid_yet, signature_invalid, invalid, other
+func FailureCounts() map[string]uint64 {
+	failuresMu.Lock()
+	defer failuresMu.Unlock()
+	result := make(map[string]uint64, len(failures))
+	for reason, n := range failures {
+		result[reason] = n
+	}
+	return result
+}
@@ -33,28 +33,37 @@ func EncryptionToken(params map[string]string, secret string, timeout time.Durat
 	return to.SignedString([]byte(secret))
 }
 
+var (
+	ErrMalformed   = errors.New("that's not even a token") // token 格式错误
+	ErrExpired     = errors.New("token is expired")        // token 已过期
+	ErrNotValidYet = errors.New("token not active yet")    // token 未生效
+	ErrInvalid     = errors.New("couldn't handle this token")
+)
+
 // DecryptionToken 解密token
 func DecryptionToken(token string, secret string) (map[string]string, error) {
 	t, err := jwt.ParseWithClaims(token, &Token{}, Secret(secret))
 	if err != nil {
 		if ve, ok := err.(*jwt.ValidationError); ok {
 			if ve.Errors&jwt.ValidationErrorMalformed != 0 {
-				return nil, errors.New("that's not even a token")
+				return nil, fail("malformed", ErrMalformed)
 			} else if ve.Errors&jwt.ValidationErrorExpired != 0 {
-				return nil, errors.New("token is expired")
+				return nil, fail("expired", ErrExpired)
 			} else if ve.Errors&jwt.ValidationErrorNotValidYet != 0 {
-				return nil, errors.New("token not active yet")
+				return nil, fail("not_valid_yet", ErrNotValidYet)
+			} else if ve.Errors&jwt.ValidationErrorSignatureInvalid != 0 {
+				return nil, fail("signature_invalid", ErrInvalid)
 			} else {
-				return nil, errors.New("couldn't handle this token")
+				return nil, fail("invalid", ErrInvalid)
 			}
 		} else {
-			return nil, err
+			return nil, fail("other", err)
 		}
 	}
 	if params, ok := t.Claims.(*Token); ok && t.Valid {
 		return params.Params, nil
 	}
-	return nil, errors.New("couldn't handle this token")
+	return nil, fail("invalid", ErrInvalid)
 }
 
 // Secret 安全认证
@@ -0,0 +1,37 @@
+package log
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+// fieldsKey context key of the log fields
+type fieldsKey struct{}
+
+// WithFields put log fields into the context, FromContext adds them to the logger
+func WithFields(ctx context.Context, keysAndValues ...interface{}) context.Context {
+	old := Fields(ctx)
+	fields := make([]interface{}, 0, len(old)+len(keysAndValues))
+	fields = append(append(fields, old...), keysAndValues...)
+	return context.WithValue(ctx, fieldsKey{}, fields)
+}
+
+// Fields get the log fields put into the context
+func Fields(ctx context.Context) []interface{} {
+	if ctx == nil {
+		return nil
+	}
+	fields, _ := ctx.Value(fieldsKey{}).([]interface{})
+	return fields
+}
+
+// FromContext get the logger carrying the fields of the context
+// demo: the request id and trace id put by the ginx Trace middleware
+func FromContext(ctx context.Context) *zap.SugaredLogger {
+	checkLogger()
+	if fields := Fields(ctx); len(fields) > 0 {
+		return logger.With(fields...)
+	}
+	return logger
+}
@@ -0,0 +1,28 @@
+package log
+
+import (
+	"sync/atomic"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// levelCounts written lines per level, index is level - DebugLevel
+var levelCounts [zapcore.FatalLevel - zapcore.DebugLevel + 1]uint64
+
+// countLevel zap hook counting the written lines
+func countLevel(entry zapcore.Entry) error {
+	if entry.Level >= zapcore.DebugLevel && entry.Level <= zapcore.FatalLevel {
+		atomic.AddUint64(&levelCounts[entry.Level-zapcore.DebugLevel], 1)
+	}
+	return nil
+}
+
+// Counts written lines per level, demo: {"info": 10, "error": 1}
+func Counts() map[string]uint64 {
+	result := make(map[string]uint64, len(levelCounts))
+	for i := range levelCounts {
+		level := zapcore.Level(i) + zapcore.DebugLevel
+		result[level.String()] = atomic.LoadUint64(&levelCounts[i])
+	}
+	return result
+}
@@ -105,7 +105,7 @@ func (log *loggerParam) generateLogger() *zap.SugaredLogger {
 	caller := zap.AddCaller()
 
 	development := zap.Development()
-	return zap.New(core, caller, development, zap.Fields()).Sugar()
+	return zap.New(core, caller, development, zap.Fields(), zap.Hooks(countLevel)).Sugar()
 }
 
 func GetWrite(path string, maxSize, maxBackups, maxAge int, compress bool) io.Writer {
@@ -117,3 +117,21 @@ func GetWrite(path string, maxSize, maxBackups, maxAge int, compress bool) io.Wr
 		Compress:   compress,   // 是否压缩
 	}
 }
+
+// Sync flush the logger buffer
+// do nothing if the logger is not init
+func Sync() error {
+	mu.Lock()
+	defer mu.Unlock()
+	if logger == nil {
+		return nil
+	}
+	return logger.Sync()
+}
+
+// IsInit check whether the logger is init
+func IsInit() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return logger != nil
+}
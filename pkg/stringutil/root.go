@@ -5,8 +5,11 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/md5"
+	"crypto/rand"
 	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/base64"
+	"errors"
 	"fmt"
 )
 
@@ -64,7 +67,7 @@ func AesEncrypt(v, k string) (string, error) {
 func AesDecrypt(v, k string) (string, error) {
 	crypted, err := base64.StdEncoding.DecodeString(v)
 	if err != nil {
-		return "", err
+		return "", ErrAesDecrypt
 	}
 	key := []byte(k)
 	block, err := aes.NewCipher(key)
@@ -72,10 +75,65 @@ func AesDecrypt(v, k string) (string, error) {
 		return "", err
 	}
 	blockSize := block.BlockSize()
+	if len(crypted) == 0 || len(crypted)%blockSize != 0 {
+		return "", ErrAesDecrypt
+	}
 	blockMode := cipher.NewCBCDecrypter(block, key[:blockSize])
 	origData := make([]byte, len(crypted))
 	blockMode.CryptBlocks(origData, crypted)
 	length := len(origData)
 	unpadding := int(origData[length-1])
+	// every pad byte is checked, the result is the same error whatever is wrong
+	valid := subtle.ConstantTimeLessOrEq(1, unpadding) & subtle.ConstantTimeLessOrEq(unpadding, blockSize)
+	for i := 1; i <= blockSize; i++ {
+		outside := subtle.ConstantTimeLessOrEq(i, unpadding) ^ 1
+		valid &= outside | subtle.ConstantTimeByteEq(origData[length-i], byte(unpadding))
+	}
+	if valid != 1 {
+		return "", ErrAesDecrypt
+	}
 	return string(origData[:(length - unpadding)]), nil
 }
+
+// ErrAesDecrypt the ciphertext can not be decrypted, whatever the reason
+var ErrAesDecrypt = errors.New("aes decrypt: invalid data")
+
+// AesGcmEncrypt return the base64 of the random nonce followed by the AES-GCM sealed value
+// unlike AesEncrypt the ciphertext is authenticated, so it can not be altered
+func AesGcmEncrypt(v, k string) (string, error) {
+	gcm, err := newGCM(k)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize(), gcm.NonceSize()+len(v)+gcm.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, []byte(v), nil)), nil
+}
+
+// AesGcmDecrypt return the value sealed by AesGcmEncrypt, ErrAesDecrypt if it is not authentic
+func AesGcmDecrypt(v, k string) (string, error) {
+	gcm, err := newGCM(k)
+	if err != nil {
+		return "", err
+	}
+	data, err := base64.StdEncoding.DecodeString(v)
+	if err != nil || len(data) < gcm.NonceSize()+gcm.Overhead() {
+		return "", ErrAesDecrypt
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", ErrAesDecrypt
+	}
+	return string(plain), nil
+}
+
+// newGCM AES-GCM of the 16, 24 or 32 bytes key
+func newGCM(k string) (cipher.AEAD, error) {
+	block, err := aes.NewCipher([]byte(k))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -9,10 +9,12 @@ import (
 
 var (
 	validateMap map[string]validator.Func
+	executed    bool // the validators are registered, Put registers the new ones at once
 	mu          sync.Mutex
 )
 
 // Put put validator func
+// the validators put after Execute are registered immediately
 func Put(key string, value validator.Func) {
 	mu.Lock()
 	defer mu.Unlock()
@@ -20,13 +22,32 @@ func Put(key string, value validator.Func) {
 		validateMap = make(map[string]validator.Func)
 	}
 	validateMap[key] = value
+	if executed {
+		register(key, value)
+	}
 }
 
 // Execute execute puts validator
 func Execute() {
+	mu.Lock()
+	defer mu.Unlock()
+	executed = true
+	for key, val := range validateMap {
+		register(key, val)
+	}
+}
+
+// register register the validator on the gin binding engine
+func register(key string, value validator.Func) {
 	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
-		for key, val := range validateMap {
-			v.RegisterValidation(key, val)
-		}
+		v.RegisterValidation(key, value)
 	}
 }
+
+// Lookup get the validator func put by key
+func Lookup(key string) (validator.Func, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	val, ok := validateMap[key]
+	return val, ok
+}